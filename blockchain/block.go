@@ -2,12 +2,13 @@ package blockchain
 
 import (
 	"bytes"
-	"crypto/sha256"
+	"context"
 	"encoding/gob"
 	"fmt"
 	"strings"
 	"time"
 
+	"github.com/casalettoj/chroma/config"
 	util "github.com/casalettoj/chroma/utils"
 )
 
@@ -18,6 +19,8 @@ type Block struct {
 	PrevHash     []byte
 	Hash         []byte
 	Nonce        int
+	Bits         uint32
+	Height       int
 }
 
 // Serialize returns a byte array serialization
@@ -28,25 +31,34 @@ func (b *Block) Serialize() []byte {
 	return result.Bytes()
 }
 
-// HashTransactions returns a []byte hash representation of all txIDs in a Tx
+// HashTransactions returns the merkle root of all txIDs in the block
 func (b *Block) HashTransactions() []byte {
-	var txHashes [][]byte
-	var txHash [32]byte
+	return b.MerkleTree().RootHash()
+}
 
+// MerkleTree returns the merkle tree built over the txIDs in the block
+func (b *Block) MerkleTree() *MerkleTree {
+	var txIDs [][]byte
 	for _, tx := range b.Transactions {
-		txHashes = append(txHashes, tx.ID)
+		txIDs = append(txIDs, tx.ID)
 	}
-	txHash = sha256.Sum256(bytes.Join(txHashes, []byte{}))
-	return txHash[:]
+	return NewMerkleTree(txIDs)
+}
+
+// MerkleProof returns a proof that the tx with the given ID is in the block, checkable against HashTransactions
+func (b *Block) MerkleProof(txID []byte) (*MerkleProof, error) {
+	return b.MerkleTree().Proof(txID)
 }
 
 func (b *Block) String() string {
 	var lines []string
 	lines = append(lines, fmt.Sprintf("====BLOCK %x====\n", b.Hash))
+	lines = append(lines, fmt.Sprintf("Height: %d\n", b.Height))
 	if b.PrevHash != nil {
 		lines = append(lines, fmt.Sprintf("Prev. hash: %x\n", b.PrevHash))
 	}
-	lines = append(lines, fmt.Sprintf("Tx Hash: %x\n", b.HashTransactions()))
+	lines = append(lines, fmt.Sprintf("Merkle Root: %x\n", b.HashTransactions()))
+	lines = append(lines, fmt.Sprintf("Bits: %08x\n", b.Bits))
 	lines = append(lines, fmt.Sprintln("Transactions:"))
 	for _, tx := range b.Transactions {
 		lines = append(lines, fmt.Sprintln(tx))
@@ -55,24 +67,27 @@ func (b *Block) String() string {
 }
 
 // DeserializeBlock deserializes a byte array into a Block struct
-func DeserializeBlock(bbytes []byte) *Block {
+func DeserializeBlock(bbytes []byte) (*Block, error) {
 	var block Block
 	decoder := gob.NewDecoder(bytes.NewReader(bbytes))
-	util.CheckAnxiety(decoder.Decode(&block))
-	return &block
+	if err := decoder.Decode(&block); err != nil {
+		return nil, fmt.Errorf("%w: block: %v", ErrCorruptData, err)
+	}
+	return &block, nil
 }
 
-// NewBlock creates a new block
-func NewBlock(transactions []*Transaction, prevHash []byte) *Block {
-	block := &Block{time.Now().Unix(), transactions, prevHash, []byte{}, 0}
-	pow := NewProofOfWork(block)
-	nonce, hash := pow.Run()
-	block.Hash = hash
-	block.Nonce = nonce
-	return block
+// NewBlock creates a new block at the given height, mined by DefaultMiner at the difficulty given by bits.
+// Returns the miner's error if it can't be mined.
+func NewBlock(transactions []*Transaction, prevHash []byte, height int, bits uint32) (*Block, error) {
+	block := &Block{time.Now().Unix(), transactions, prevHash, []byte{}, 0, bits, height}
+	// Without a way to cancel it mining only stops once it succeeds
+	if err := DefaultMiner.Mine(context.Background(), block); err != nil {
+		return nil, err
+	}
+	return block, nil
 }
 
-// GenerateGenesisBlock creates a new genesis block for a new blockchain with a special message
-func GenerateGenesisBlock(coinbase *Transaction) *Block {
-	return NewBlock([]*Transaction{coinbase}, []byte{})
+// GenerateGenesisBlock creates a new genesis block for a new blockchain on the network with a special message
+func GenerateGenesisBlock(network *config.Network, coinbase *Transaction) (*Block, error) {
+	return NewBlock([]*Transaction{coinbase}, []byte{}, 0, TargetToCompact(PowLimit(network)))
 }
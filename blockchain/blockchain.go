@@ -1,18 +1,15 @@
 package blockchain
 
 import (
-	"bytes"
-	"crypto/ecdsa"
+	"context"
 	"encoding/hex"
-	"errors"
 	"fmt"
-	"log"
-	"os"
-
-	"github.com/btcsuite/btcutil/base58"
+	"time"
 
+	"github.com/casalettoj/chroma/config"
 	conf "github.com/casalettoj/chroma/constants"
 	util "github.com/casalettoj/chroma/utils"
+	wallet "github.com/casalettoj/chroma/wallet"
 	bolt "github.com/coreos/bbolt"
 )
 
@@ -20,49 +17,87 @@ import (
 type Blockchain struct {
 	Tip []byte
 	DB  *bolt.DB
+	// Network is the network the chain belongs to
+	Network *config.Network
 }
 
 // GetBalance returns the balance of the address given for the current bc
-func (bc *Blockchain) GetBalance(address string) int {
+func (bc *Blockchain) GetBalance(address string) (int, error) {
 	total := 0
-	pubKeyHash := base58.Decode(address)
-	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4]
+	pubKeyHash, _, err := wallet.DecodeAddress(bc.Network, address)
+	if err != nil {
+		return 0, err
+	}
 
-	UTXOs := GetUTXOsForAddress(bc, pubKeyHash)
+	UTXOs, err := GetUTXOsForAddress(bc, pubKeyHash)
+	if err != nil {
+		return 0, err
+	}
 
 	for _, UTXO := range UTXOs {
 		total += UTXO.Value
 	}
-	return total
+	return total, nil
 }
 
-// FindTransaction traverses the blockchain looking for the TX matching ID
-func (bc *Blockchain) FindTransaction(ID []byte) (Transaction, error) {
+// GetUsedPubKeyHashes returns the hex public key hash of every address an output in the main chain has been locked to,
+// spent or not
+func (bc *Blockchain) GetUsedPubKeyHashes() (map[string]bool, error) {
+	used := make(map[string]bool)
 	bci := bc.Iterator()
 	for {
-		block := bci.Next()
-		fmt.Println(len(block.Transactions))
+		block, err := bci.Next()
+		if err != nil {
+			return nil, err
+		}
 		for _, tx := range block.Transactions {
-			if bytes.Compare(tx.ID, ID) == 0 {
-				return *tx, nil
+			for _, out := range tx.Vout {
+				if pubKeyHash := out.ScriptPubKey.PubKeyHash(); pubKeyHash != nil {
+					used[hex.EncodeToString(pubKeyHash)] = true
+				}
 			}
 		}
 		if bci.IsGenesisBlock() {
 			break
 		}
 	}
-	return Transaction{}, errors.New("tx Not found in chain")
+	return used, nil
+}
+
+// FindTransaction looks up the main chain TX matching ID in the tx index
+func (bc *Blockchain) FindTransaction(ID []byte) (Transaction, error) {
+	var location *TxLocation
+	err := bc.DB.View(func(tx *bolt.Tx) error {
+		encodedLocation := tx.Bucket([]byte(conf.DBtxbucket)).Get(ID)
+		if encodedLocation == nil {
+			return fmt.Errorf("%w: %x", ErrTxNotFound, ID)
+		}
+		var err error
+		location, err = DeserializeTxLocation(encodedLocation)
+		return err
+	})
+	if err != nil {
+		return Transaction{}, err
+	}
+	block, err := bc.GetBlock(location.BlockHash)
+	if err != nil {
+		return Transaction{}, err
+	}
+	return *block.Transactions[location.Index], nil
 }
 
-// SignTransaction signs a transaction with a private key
-func (bc *Blockchain) SignTransaction(tx *Transaction, privateKey ecdsa.PrivateKey) {
+// SignTransaction signs the inputs of a transaction that a wallet's key can sign for,
+// returning the number of inputs signed
+func (bc *Blockchain) SignTransaction(tx *Transaction, w *wallet.Wallet, hashType SigHashType) (int, error) {
 	prevTxs := make(map[string]Transaction)
 	for _, vin := range tx.Vin {
 		prevTx, err := bc.FindTransaction(vin.TxID)
-		util.CheckAnxiety(err)
+		if err != nil {
+			return 0, err
+		}
 		prevTxs[hex.EncodeToString(prevTx.ID)] = prevTx
 	}
-	tx.Sign(privateKey, prevTxs)
+	return tx.Sign(w.PrivateKey, w.PublicKey, prevTxs, hashType)
 }
 
 // VerifyTransaction verifies the signatures of a transaction's inputs
@@ -82,49 +117,159 @@ func (bc *Blockchain) VerifyTransaction(tx *Transaction) bool {
 	return verified
 }
 
+// GetBestHeight returns the height of the tip, counting the genesis block as height 0
+func (bc *Blockchain) GetBestHeight() (int, error) {
+	tip, err := bc.GetBlock(bc.Tip)
+	if err != nil {
+		return 0, err
+	}
+	return tip.Height, nil
+}
+
+// GetBlockByHeight returns the block at the given height of the main chain
+func (bc *Blockchain) GetBlockByHeight(height int) (Block, error) {
+	var hash []byte
+	err := bc.DB.View(func(tx *bolt.Tx) error {
+		hash = append([]byte{}, tx.Bucket([]byte(conf.DBheightsbucket)).Get(util.Int64ToByteArray(int64(height)))...)
+		return nil
+	})
+	if err != nil {
+		return Block{}, err
+	}
+	if len(hash) == 0 {
+		return Block{}, fmt.Errorf("%w: no block at height %d", ErrBlockNotFound, height)
+	}
+	return bc.GetBlock(hash)
+}
+
+// GetBlockHashes returns the hashes of every block in the chain, ordered from the tip back to genesis
+func (bc *Blockchain) GetBlockHashes() ([][]byte, error) {
+	var hashes [][]byte
+	bci := bc.Iterator()
+	for {
+		block, err := bci.Next()
+		if err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, block.Hash)
+		if bci.IsGenesisBlock() {
+			break
+		}
+	}
+	return hashes, nil
+}
+
+// HasBlock returns whether a block with the given hash is stored in the blocks bucket
+func (bc *Blockchain) HasBlock(hash []byte) (bool, error) {
+	found := false
+	err := bc.DB.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(conf.DBblocksbucket))
+		found = bucket.Get(hash) != nil
+		return nil
+	})
+	return found, err
+}
+
+// GetBlock returns the block stored under the given hash
+func (bc *Blockchain) GetBlock(hash []byte) (Block, error) {
+	var block Block
+	err := bc.DB.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(conf.DBblocksbucket))
+		encodedBlock := bucket.Get(hash)
+		if encodedBlock == nil {
+			return fmt.Errorf("%w: %x", ErrBlockNotFound, hash)
+		}
+		decoded, err := DeserializeBlock(encodedBlock)
+		if err != nil {
+			return err
+		}
+		block = *decoded
+		return nil
+	})
+	return block, err
+}
+
 // Iterator give iterator
 func (bc *Blockchain) Iterator() *Iterator {
 	iterator := &Iterator{bc.Tip, bc.DB}
 	return iterator
 }
 
-// MineBlock mines a block with the given transactions
-func (bc *Blockchain) MineBlock(Txs []*Transaction) *Block {
-	var lastHash []byte
-
-	for _, tx := range Txs {
-		if !bc.VerifyTransaction(tx) {
-			log.Panic("ERROR: Invalid Tx in Block")
-		}
+// MineBlock mines a block with the given transactions on top of the tip with DefaultMiner.
+// Returns an error wrapping ErrInvalidTransaction if they break the consensus rules (see NewBlockTemplate),
+// or the miner's error.
+func (bc *Blockchain) MineBlock(Txs []*Transaction) (*Block, error) {
+	newBlock, err := bc.NewBlockTemplate(Txs)
+	if err != nil {
+		return nil, err
+	}
+	// Without a way to cancel it mining only stops once it succeeds
+	if err = DefaultMiner.Mine(context.Background(), newBlock); err != nil {
+		return nil, err
 	}
 
-	util.CheckAnxiety(bc.DB.View(func(tx *bolt.Tx) error {
+	// Store the block and connect it to the UTXO set together so a crash can't leave them out of step
+	err = bc.DB.Update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(conf.DBblocksbucket))
-		lastHash = bucket.Get([]byte(conf.DBlasthash))
-		return nil
-	}))
+		if err := bucket.Put(newBlock.Hash, newBlock.Serialize()); err != nil {
+			return err
+		}
+		if err := putChainWork(tx, newBlock); err != nil {
+			return err
+		}
+		return connectTip(tx, newBlock)
+	})
+	if err != nil {
+		return nil, err
+	}
+	bc.Tip = newBlock.Hash
+	return newBlock, nil
+}
 
-	newBlock := NewBlock(Txs, lastHash)
+// NewBlockTemplate returns an unmined block holding the given transactions on top of the tip, at the difficulty
+// it needs there, for a Miner to find the proof of work of.
+// Returns an error wrapping ErrInvalidTransaction if the transactions break the consensus rules, checked as they
+// would be once the block is mined: a coinbase tx first paying at most the subsidy plus fees, then transactions
+// spending mature, unspent outputs they unlock.
+func (bc *Blockchain) NewBlockTemplate(Txs []*Transaction) (*Block, error) {
+	var lastHash []byte
 
-	util.CheckAnxiety(bc.DB.Update(func(tx *bolt.Tx) error {
+	err := bc.DB.View(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(conf.DBblocksbucket))
-		util.CheckAnxiety(bucket.Put(newBlock.Hash, newBlock.Serialize()))
-		util.CheckAnxiety(bucket.Put([]byte(conf.DBlasthash), newBlock.Hash))
-		bc.Tip = newBlock.Hash
+		// Bolt values are only valid for the life of the transaction, so keep a copy
+		lastHash = append([]byte{}, bucket.Get([]byte(conf.DBlasthash))...)
 		return nil
-	}))
+	})
+	if err != nil {
+		return nil, err
+	}
+	lastBlock, err := bc.GetBlock(lastHash)
+	if err != nil {
+		return nil, err
+	}
+	bits, err := bc.GetNextBits(&lastBlock)
+	if err != nil {
+		return nil, err
+	}
 
-	return newBlock
+	block := &Block{time.Now().Unix(), Txs, lastHash, []byte{}, 0, bits, lastBlock.Height + 1}
+	if err = checkTransactions(bc.Network, block, newUTXOView(bc)); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTransaction, err)
+	}
+	return block, nil
 }
 
 // GetUTXOs gets all UTXOs in the blockchain
-func (bc *Blockchain) GetUTXOs() map[string]TxOutputs {
+func (bc *Blockchain) GetUTXOs() (map[string]TxOutputs, error) {
 	UTXOs := make(map[string]TxOutputs)
 	spentTXOs := make(map[string][]int)
 	bci := bc.Iterator()
 
 	for {
-		block := bci.Next()
+		block, err := bci.Next()
+		if err != nil {
+			return nil, err
+		}
 		// For every transaction in the block...
 		for _, tx := range block.Transactions {
 			txID := hex.EncodeToString(tx.ID)
@@ -141,10 +286,13 @@ func (bc *Blockchain) GetUTXOs() map[string]TxOutputs {
 						}
 					}
 				}
-				// If the output hasn't been spent and it can be unlocked by the address, add tx to the UTXset.
-				if !spent {
+				// If the output hasn't been spent and can ever be, add tx to the UTXset.
+				if !spent && !out.ScriptPubKey.IsUnspendable() {
 					outputs := UTXOs[txID]
-					outputs.Outputs = append(outputs.Outputs, out)
+					if outputs.Outputs == nil {
+						outputs = TxOutputs{Outputs: make(map[int]TxOutput), Height: block.Height, Coinbase: tx.IsCoinbaseTx()}
+					}
+					outputs.Outputs[outIndex] = out
 					UTXOs[txID] = outputs
 				}
 			}
@@ -163,49 +311,127 @@ func (bc *Blockchain) GetUTXOs() map[string]TxOutputs {
 		}
 	}
 
-	return UTXOs
+	return UTXOs, nil
 }
 
-// OpenBlockchain opens a preexisting blockchain and returns Tip and DB
-func OpenBlockchain() *Blockchain {
-	if !util.DoesDBExist() {
-		fmt.Println("No existing Chroma chain.  Create DB first.")
-		os.Exit(1)
+// OpenBlockchain opens the preexisting blockchain of cfg's network and returns Tip and DB.
+// Returns ErrChainNotFound if there is no chain to open, an error wrapping ErrChainInUse if a running node holds it
+// or one wrapping ErrLegacyChain if it was created by a version whose blocks had no difficulty bits.
+func OpenBlockchain(cfg *config.Config) (*Blockchain, error) {
+	if err := cfg.Network.Validate(); err != nil {
+		return nil, err
+	}
+	exists, err := util.DoesDBExist(cfg.DBFile())
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrChainNotFound
 	}
 
 	var tip []byte
-	db, err := bolt.Open(conf.DBdbfile, 0600, nil)
-	util.CheckAnxiety(err)
+	db, err := openDB(cfg.DBFile())
+	if err != nil {
+		return nil, err
+	}
 
-	util.CheckAnxiety(db.Update(func(tx *bolt.Tx) error {
+	reindex := false
+	err = db.Update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(conf.DBblocksbucket))
-		tip = bucket.Get([]byte(conf.DBlasthash))
+		if bucket == nil {
+			return fmt.Errorf("%w: %s has no blocks bucket", ErrCorruptData, cfg.DBFile())
+		}
+		tip = append([]byte{}, bucket.Get([]byte(conf.DBlasthash))...)
+		tipBlock, err := DeserializeBlock(bucket.Get(tip))
+		if err != nil {
+			return err
+		}
+		if tipBlock.Bits == 0 {
+			return fmt.Errorf("%w: move %s aside and create a new chain", ErrLegacyChain, cfg.DBFile())
+		}
+		reindex = tx.Bucket([]byte(conf.DBtxbucket)) == nil
 		return nil
-	}))
-	bc := &Blockchain{DB: db, Tip: tip}
-	return bc
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	bc := &Blockchain{DB: db, Tip: tip, Network: cfg.Network}
+	// Chains with difficulty bits but created before the tx index was kept need it rebuilt, along with everything
+	// else derived from the blocks
+	if reindex {
+		if err = bc.Reindex(); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return bc, nil
 }
 
-// CreateBlockchain establishes a blockchain with a genesis block
-func CreateBlockchain(address string) *Blockchain {
-	if util.DoesDBExist() {
-		fmt.Println("Chroma chain already exists.")
-		os.Exit(1)
+// openDB opens the chain database in file, returning an error wrapping ErrChainInUse if another process doesn't
+// let go of it within DBopentimeout. Bolt allows a single process at a time, so this is usually a running node.
+func openDB(file string) (*bolt.DB, error) {
+	db, err := bolt.Open(file, 0600, &bolt.Options{Timeout: conf.DBopentimeout * time.Second})
+	if err == bolt.ErrTimeout {
+		return nil, fmt.Errorf("%w: %s", ErrChainInUse, file)
+	}
+	return db, err
+}
+
+// CreateBlockchain establishes a blockchain for cfg's network with a genesis block paying address.
+// Returns ErrChainExists if there already is one, or an error wrapping wallet.ErrInvalidAddress if address isn't valid.
+func CreateBlockchain(cfg *config.Config, address string) (*Blockchain, error) {
+	if err := cfg.Network.Validate(); err != nil {
+		return nil, err
+	}
+	exists, err := util.DoesDBExist(cfg.DBFile())
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrChainExists
+	}
+	coinbase, err := NewCoinbaseTx(cfg.Network, address, cfg.Network.GenesisMessage, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	genesisBlock, err := GenerateGenesisBlock(cfg.Network, coinbase)
+	if err != nil {
+		return nil, err
+	}
+	if err = cfg.MakeDir(); err != nil {
+		return nil, err
 	}
 
 	var tip []byte
-	db, err := bolt.Open(conf.DBdbfile, 0600, nil)
-	util.CheckAnxiety(err)
+	db, err := openDB(cfg.DBFile())
+	if err != nil {
+		return nil, err
+	}
 
-	util.CheckAnxiety(db.Update(func(tx *bolt.Tx) error {
-		genesisBlock := GenerateGenesisBlock(NewCoinbaseTx(address, conf.Message))
+	err = db.Update(func(tx *bolt.Tx) error {
 		bucket, err := tx.CreateBucket([]byte(conf.DBblocksbucket))
-		util.CheckAnxiety(err)
-		util.CheckAnxiety(bucket.Put(genesisBlock.Hash, genesisBlock.Serialize()))
-		util.CheckAnxiety(bucket.Put([]byte(conf.DBlasthash), genesisBlock.Hash))
+		if err != nil {
+			return err
+		}
+		for _, name := range []string{conf.DButxobucket, conf.DBworkbucket, conf.DBundobucket, conf.DBheightsbucket, conf.DBtxbucket} {
+			if _, err = tx.CreateBucket([]byte(name)); err != nil {
+				return err
+			}
+		}
+		if err = bucket.Put(genesisBlock.Hash, genesisBlock.Serialize()); err != nil {
+			return err
+		}
+		if err = putChainWork(tx, genesisBlock); err != nil {
+			return err
+		}
 		tip = genesisBlock.Hash
-		return nil
-	}))
-	bc := &Blockchain{tip, db}
-	return bc
+		return connectTip(tx, genesisBlock)
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	bc := &Blockchain{Tip: tip, DB: db, Network: cfg.Network}
+	return bc, nil
 }
@@ -0,0 +1,50 @@
+package blockchain
+
+import (
+	"fmt"
+	"math/big"
+
+	conf "github.com/casalettoj/chroma/constants"
+	bolt "github.com/coreos/bbolt"
+)
+
+// BlockWork returns the expected number of hashes needed to mine a block at the given bits: 2^256 / (target+1)
+func BlockWork(bits uint32) *big.Int {
+	target := CompactToTarget(bits)
+	if target.Sign() <= 0 {
+		return big.NewInt(0)
+	}
+	work := new(big.Int).Lsh(big.NewInt(1), 256)
+	return work.Div(work, target.Add(target, big.NewInt(1)))
+}
+
+// GetChainWork returns the cumulative work of the chain ending at the block with the given hash.
+// Every stored block has its work recorded, so it's an error wrapping ErrCorruptData if there is none.
+func (bc *Blockchain) GetChainWork(hash []byte) (*big.Int, error) {
+	work := new(big.Int)
+	err := bc.DB.View(func(tx *bolt.Tx) error {
+		encoded := tx.Bucket([]byte(conf.DBworkbucket)).Get(hash)
+		if encoded == nil {
+			return fmt.Errorf("%w: no chain work for block %x", ErrCorruptData, hash)
+		}
+		work.SetBytes(encoded)
+		return nil
+	})
+	return work, err
+}
+
+// putChainWork records the cumulative work of a block whose parent's work is already stored.
+// Returns an error wrapping ErrCorruptData if it isn't, rather than counting the block's work from zero.
+func putChainWork(tx *bolt.Tx, block *Block) error {
+	bucket := tx.Bucket([]byte(conf.DBworkbucket))
+	work := new(big.Int)
+	if len(block.PrevHash) != 0 {
+		parentWork := bucket.Get(block.PrevHash)
+		if parentWork == nil {
+			return fmt.Errorf("%w: no chain work for block %x, the parent of %x", ErrCorruptData, block.PrevHash, block.Hash)
+		}
+		work.SetBytes(parentWork)
+	}
+	work.Add(work, BlockWork(block.Bits))
+	return bucket.Put(block.Hash, work.Bytes())
+}
@@ -0,0 +1,85 @@
+package blockchain
+
+import (
+	"math/big"
+
+	"github.com/casalettoj/chroma/config"
+	conf "github.com/casalettoj/chroma/constants"
+)
+
+// PowLimit returns the easiest target a block on the network may be mined at, that of its genesis block
+func PowLimit(network *config.Network) *big.Int {
+	limit := big.NewInt(1)
+	return limit.Lsh(limit, uint(256-network.TargetBits))
+}
+
+// CompactToTarget expands the compact "bits" form of a target stored in a block:
+// the high byte is the length of the target in bytes and the low three bytes are its most significant bytes.
+func CompactToTarget(bits uint32) *big.Int {
+	mantissa := int64(bits & 0x007fffff)
+	exponent := uint(bits >> 24)
+	target := big.NewInt(mantissa)
+	if exponent <= 3 {
+		return target.Rsh(target, 8*(3-exponent))
+	}
+	return target.Lsh(target, 8*(exponent-3))
+}
+
+// TargetToCompact packs a target into the compact "bits" form, dropping everything past its three most significant bytes
+func TargetToCompact(target *big.Int) uint32 {
+	size := uint((target.BitLen() + 7) / 8)
+	var mantissa uint32
+	if size <= 3 {
+		mantissa = uint32(target.Uint64() << (8 * (3 - size)))
+	} else {
+		mantissa = uint32(new(big.Int).Rsh(target, 8*(size-3)).Uint64())
+	}
+	// The mantissa's top bit is a sign bit, so shift it out of the way into the exponent
+	if mantissa&0x00800000 != 0 {
+		mantissa >>= 8
+		size++
+	}
+	return uint32(size)<<24 | mantissa
+}
+
+// GetNextBits returns the difficulty the block after prev must be mined at. Every RetargetInterval blocks of the
+// chain's network the target is scaled by how long the last window actually took compared to POWtargetblocktime
+// per block, at most by a factor of 4 either way, and never past PowLimit.
+func (bc *Blockchain) GetNextBits(prev *Block) (uint32, error) {
+	interval := bc.Network.RetargetInterval
+	if interval == 0 || (prev.Height+1)%interval != 0 {
+		return prev.Bits, nil
+	}
+
+	first := prev
+	for i := 0; i < interval-1; i++ {
+		parent, err := bc.GetBlock(first.PrevHash)
+		if err != nil {
+			return 0, err
+		}
+		first = &parent
+	}
+
+	// The window is timed from its first block to its last, so it spans interval-1 block times
+	expected := int64((interval - 1) * conf.POWtargetblocktime)
+	return retarget(prev.Bits, prev.Timestamp-first.Timestamp, expected, PowLimit(bc.Network)), nil
+}
+
+// retarget returns the bits of the target given by bits scaled by actual/expected, the seconds a window of blocks took
+// against the seconds it should have, at most by a factor of 4 either way and never past limit
+func retarget(bits uint32, actual, expected int64, limit *big.Int) uint32 {
+	if actual < expected/4 {
+		actual = expected / 4
+	}
+	if actual > expected*4 {
+		actual = expected * 4
+	}
+
+	target := CompactToTarget(bits)
+	target.Mul(target, big.NewInt(actual))
+	target.Div(target, big.NewInt(expected))
+	if target.Cmp(limit) > 0 {
+		target = limit
+	}
+	return TargetToCompact(target)
+}
@@ -0,0 +1,48 @@
+package blockchain
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/casalettoj/chroma/config"
+)
+
+// powerOfTwo returns 2^n
+func powerOfTwo(n uint) *big.Int {
+	return new(big.Int).Lsh(big.NewInt(1), n)
+}
+
+func TestCompactRoundTrip(t *testing.T) {
+	for _, target := range []*big.Int{big.NewInt(1), big.NewInt(0x7fffff), big.NewInt(0x800000), powerOfTwo(230), PowLimit(&config.Mainnet)} {
+		if got := CompactToTarget(TargetToCompact(target)); got.Cmp(target) != 0 {
+			t.Errorf("target %x came back as %x", target, got)
+		}
+	}
+}
+
+func TestRetarget(t *testing.T) {
+	limit := PowLimit(&config.Mainnet) // 2^238
+	// Divisible by 4, so the clamps scale the target exactly
+	expected := int64(12 * 30)
+	tests := []struct {
+		name   string
+		target *big.Int
+		actual int64
+		want   *big.Int
+	}{
+		{"on time", powerOfTwo(230), expected, powerOfTwo(230)},
+		{"twice as slow", powerOfTwo(230), 2 * expected, powerOfTwo(231)},
+		{"twice as fast", powerOfTwo(230), expected / 2, powerOfTwo(229)},
+		{"clamped at x4", powerOfTwo(230), 10 * expected, powerOfTwo(232)},
+		{"clamped at /4", powerOfTwo(230), 1, powerOfTwo(228)},
+		{"timestamps going backwards", powerOfTwo(230), -expected, powerOfTwo(228)},
+		{"capped at the limit", powerOfTwo(237), 4 * expected, limit},
+		{"at the limit already", limit, 2 * expected, limit},
+	}
+	for _, test := range tests {
+		got := CompactToTarget(retarget(TargetToCompact(test.target), test.actual, expected, limit))
+		if got.Cmp(test.want) != 0 {
+			t.Errorf("%s: got target %x, want %x", test.name, got, test.want)
+		}
+	}
+}
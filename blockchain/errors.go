@@ -0,0 +1,28 @@
+package blockchain
+
+import "errors"
+
+// Errors returned by the blockchain package. Most are wrapped with details, so compare them with errors.Is.
+var (
+	// ErrChainNotFound is returned when opening a chain whose database doesn't exist
+	ErrChainNotFound = errors.New("no existing CHROMA chain")
+	// ErrChainExists is returned when creating a chain whose database already exists
+	ErrChainExists = errors.New("CHROMA chain already exists")
+	// ErrChainInUse is returned when another process, such as a running node, holds the chain's database open
+	ErrChainInUse = errors.New("CHROMA chain database in use by a running node")
+	// ErrBlockNotFound is returned when a block isn't stored
+	ErrBlockNotFound = errors.New("block not found in chain")
+	// ErrTxNotFound is returned when a transaction isn't in the main chain
+	ErrTxNotFound = errors.New("tx not found in chain")
+	// ErrInsufficientFunds is returned when an address's spendable outputs don't cover a payment
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	// ErrInvalidTransaction is returned when a transaction breaks the consensus rules or can't be built or signed
+	ErrInvalidTransaction = errors.New("invalid transaction")
+	// ErrInvalidBlock is returned when a block breaks the consensus rules
+	ErrInvalidBlock = errors.New("invalid block")
+	// ErrCorruptData is returned when stored or received data can't be decoded
+	ErrCorruptData = errors.New("corrupt data")
+	// ErrLegacyChain is returned when opening a chain whose blocks predate difficulty bits and heights, which
+	// can't be upgraded since its blocks were never mined against a target the current rules could check
+	ErrLegacyChain = errors.New("CHROMA chain is from an unsupported older version")
+)
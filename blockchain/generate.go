@@ -0,0 +1,45 @@
+package blockchain
+
+import "fmt"
+
+// Generate mines n blocks on top of the tip, each holding only a coinbase tx that pays the subsidy to address,
+// and returns them oldest first. On regtest they mine instantly, so tests can quickly mature coinbases or
+// build up outputs to spend.
+func (bc *Blockchain) Generate(n int, address string) ([]*Block, error) {
+	return bc.GenerateOn(bc.Tip, n, address)
+}
+
+// GenerateOn mines n blocks like Generate, but on top of the block with the given hash, which needn't be the tip.
+// They are stored with AddBlock, so building on an older block makes a fork, and the chain reorganizes onto it
+// once it carries more work than the main chain. n can't be negative.
+func (bc *Blockchain) GenerateOn(parentHash []byte, n int, address string) ([]*Block, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("can't generate %d blocks", n)
+	}
+	parent, err := bc.GetBlock(parentHash)
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := make([]*Block, 0, n)
+	for i := 0; i < n; i++ {
+		bits, err := bc.GetNextBits(&parent)
+		if err != nil {
+			return blocks, err
+		}
+		coinbaseTx, err := NewCoinbaseTx(bc.Network, address, "", parent.Height+1, 0)
+		if err != nil {
+			return blocks, err
+		}
+		block, err := NewBlock([]*Transaction{coinbaseTx}, parent.Hash, parent.Height+1, bits)
+		if err != nil {
+			return blocks, err
+		}
+		if _, _, err = bc.AddBlock(block); err != nil {
+			return blocks, err
+		}
+		blocks = append(blocks, block)
+		parent = *block
+	}
+	return blocks, nil
+}
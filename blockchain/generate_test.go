@@ -0,0 +1,278 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/casalettoj/chroma/config"
+	"github.com/casalettoj/chroma/wallet"
+)
+
+// mineTx mines a block holding tx after a coinbase tx paying to, as a miner would
+func mineTx(bc *Blockchain, tx *Transaction, to string) error {
+	height, err := bc.GetBestHeight()
+	if err != nil {
+		return err
+	}
+	coinbaseTx, err := NewCoinbaseTx(bc.Network, to, "", height+1, 0)
+	if err != nil {
+		return err
+	}
+	_, err = bc.MineBlock([]*Transaction{coinbaseTx, tx})
+	return err
+}
+
+// newOverpayingBlock mines a block on parent whose coinbase pays one more than the subsidy, so that it is invalid
+func newOverpayingBlock(t *testing.T, bc *Blockchain, parent *Block, address string) *Block {
+	t.Helper()
+	coinbaseTx, err := NewCoinbaseTx(bc.Network, address, "", parent.Height+1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bits, err := bc.GetNextBits(parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := NewBlock([]*Transaction{coinbaseTx}, parent.Hash, parent.Height+1, bits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return block
+}
+
+// newTestChain creates a regtest chain in a temporary directory whose genesis coinbase pays a new wallet
+func newTestChain(t *testing.T) (*Blockchain, *wallet.Wallet) {
+	t.Helper()
+	cfg := &config.Config{DataDir: t.TempDir(), Network: &config.Regtest}
+	w := wallet.NewWallet(wallet.P256)
+	bc, err := CreateBlockchain(cfg, string(w.GetChromaAddress(cfg.Network)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { bc.DB.Close() })
+	return bc, w
+}
+
+func TestGenerateNegative(t *testing.T) {
+	bc, w := newTestChain(t)
+	if _, err := bc.Generate(-1, string(w.GetChromaAddress(bc.Network))); err == nil {
+		t.Error("generating -1 blocks was accepted")
+	}
+}
+
+func TestGenerateMaturity(t *testing.T) {
+	bc, w := newTestChain(t)
+	from := string(w.GetChromaAddress(bc.Network))
+	to := string(wallet.NewWallet(wallet.P256).GetChromaAddress(bc.Network))
+	genesis, err := bc.GetBlock(bc.Tip)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The genesis coinbase can first be spent in the block at height CoinbaseMaturity
+	if _, err = bc.Generate(bc.Network.CoinbaseMaturity-2, to); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = newPayment(bc, wallet.HashPublicKey(w.PublicKey), from, to, 10, 0); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("payment from an immature coinbase gave %v, want ErrInsufficientFunds", err)
+	}
+	out, err := NewUTXO(bc.Network, 10, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	immature := &Transaction{Vin: []TxInput{{TxID: genesis.Transactions[0].ID, Vout: 0}}, Vout: []TxOutput{*out}}
+	immature.ID = immature.Hash()
+	if _, err = bc.SignTransaction(immature, w, SigHashAll); err != nil {
+		t.Fatal(err)
+	}
+	if err = mineTx(bc, immature, to); !errors.Is(err, ErrInvalidTransaction) {
+		t.Errorf("mining a spend of an immature coinbase gave %v, want ErrInvalidTransaction", err)
+	}
+
+	if _, err = bc.Generate(1, to); err != nil {
+		t.Fatal(err)
+	}
+	tx, err := newPayment(bc, wallet.HashPublicKey(w.PublicKey), from, to, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = bc.SignTransaction(tx, w, SigHashAll); err != nil {
+		t.Fatal(err)
+	}
+	if err = mineTx(bc, tx, to); err != nil {
+		t.Fatal(err)
+	}
+	balance, err := bc.GetBalance(from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := GetBlockSubsidy(bc.Network, 0) - 10; balance != want {
+		t.Errorf("got balance %d, want %d", balance, want)
+	}
+}
+
+func TestGenerateReorg(t *testing.T) {
+	bc, w := newTestChain(t)
+	address := string(w.GetChromaAddress(bc.Network))
+	genesis := bc.Tip
+
+	main, err := bc.Generate(3, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	side, err := bc.GenerateOn(genesis, 3, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(bc.Tip, main[2].Hash) {
+		t.Fatal("tip moved onto a side branch with equal work")
+	}
+
+	// A block whose coinbase pays more than the subsidy makes the side branch heavier but invalid
+	invalid := newOverpayingBlock(t, bc, side[2], address)
+	if _, _, err = bc.AddBlock(invalid); !errors.Is(err, ErrInvalidBlock) {
+		t.Errorf("adding an invalid block gave %v, want ErrInvalidBlock", err)
+	}
+	if !bytes.Equal(bc.Tip, main[2].Hash) {
+		t.Error("tip moved onto an invalid branch")
+	}
+	if stored, err := bc.HasBlock(invalid.Hash); err != nil || stored {
+		t.Errorf("invalid block is still stored (%v)", err)
+	}
+	if err = bc.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	longer, err := bc.GenerateOn(side[2].Hash, 1, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(bc.Tip, longer[0].Hash) {
+		t.Fatal("tip didn't move onto the longer branch")
+	}
+	if err = bc.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	balance, err := bc.GetBalance(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 5 * GetBlockSubsidy(bc.Network, 0); balance != want {
+		t.Errorf("got balance %d, want %d", balance, want)
+	}
+}
+
+func TestInvalidBlockDescendantsRemoved(t *testing.T) {
+	bc, w := newTestChain(t)
+	address := string(w.GetChromaAddress(bc.Network))
+	genesis := bc.Tip
+
+	main, err := bc.Generate(5, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	side, err := bc.GenerateOn(genesis, 2, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Two branches build on the invalid block without outweighing the main chain, so it isn't checked yet
+	invalid := newOverpayingBlock(t, bc, side[1], address)
+	if _, _, err = bc.AddBlock(invalid); err != nil {
+		t.Fatal(err)
+	}
+	sibling, err := bc.GenerateOn(invalid.Hash, 1, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = bc.GenerateOn(invalid.Hash, 3, address); !errors.Is(err, ErrInvalidBlock) {
+		t.Errorf("outweighing the main chain with an invalid block gave %v, want ErrInvalidBlock", err)
+	}
+	if !bytes.Equal(bc.Tip, main[4].Hash) {
+		t.Error("tip moved onto an invalid branch")
+	}
+	for _, block := range []*Block{invalid, sibling[0]} {
+		if stored, err := bc.HasBlock(block.Hash); err != nil || stored {
+			t.Errorf("block %x building on the invalid block is still stored (%v)", block.Hash, err)
+		}
+	}
+	if stored, err := bc.HasBlock(side[1].Hash); err != nil || !stored {
+		t.Errorf("valid parent of the invalid block was removed (%v)", err)
+	}
+}
+
+func TestReindexSideBranchWork(t *testing.T) {
+	bc, w := newTestChain(t)
+	address := string(w.GetChromaAddress(bc.Network))
+	genesis := bc.Tip
+
+	main, err := bc.Generate(3, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	side, err := bc.GenerateOn(genesis, 2, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	work, err := bc.GetChainWork(side[1].Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = bc.Reindex(); err != nil {
+		t.Fatal(err)
+	}
+	reindexed, err := bc.GetChainWork(side[1].Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reindexed.Cmp(work) != 0 {
+		t.Errorf("side branch work went from %v to %v", work, reindexed)
+	}
+
+	// The side branch can still take over once it is heavier
+	longer, err := bc.GenerateOn(side[1].Hash, 2, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(bc.Tip, longer[1].Hash) {
+		t.Errorf("tip stayed at %x instead of moving onto the heavier side branch", main[2].Hash)
+	}
+	if err = bc.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewPaymentDeterministic(t *testing.T) {
+	bc, w := newTestChain(t)
+	from := string(w.GetChromaAddress(bc.Network))
+	to := string(wallet.NewWallet(wallet.P256).GetChromaAddress(bc.Network))
+	if _, err := bc.Generate(3, from); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bc.Generate(bc.Network.CoinbaseMaturity, to); err != nil {
+		t.Fatal(err)
+	}
+
+	// Spending all four mature coinbases needs every one of them as an input
+	amount := 4*GetBlockSubsidy(bc.Network, 0) - 1
+	var first *Transaction
+	for i := 0; i < 10; i++ {
+		tx, err := newPayment(bc, wallet.HashPublicKey(w.PublicKey), from, to, amount, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tx.Vin) != 4 {
+			t.Fatalf("payment has %d inputs, want 4", len(tx.Vin))
+		}
+		if first == nil {
+			first = tx
+		} else if !bytes.Equal(tx.ID, first.ID) {
+			t.Fatalf("the same payment got IDs %x and %x", first.ID, tx.ID)
+		}
+	}
+	for i := 1; i < len(first.Vin); i++ {
+		if bytes.Compare(first.Vin[i-1].TxID, first.Vin[i].TxID) >= 0 {
+			t.Errorf("inputs %d and %d aren't in txid order", i-1, i)
+		}
+	}
+}
@@ -0,0 +1,368 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	conf "github.com/casalettoj/chroma/constants"
+	wallet "github.com/casalettoj/chroma/wallet"
+)
+
+// scriptEngine runs the scripts that unlock a single input of a tx
+type scriptEngine struct {
+	tx      *Transaction
+	inIndex int
+	// prevScript is the locking script of the output being spent, which signatures commit to
+	prevScript Script
+	stack      [][]byte
+	// conditions holds whether each OP_IF/OP_NOTIF block the engine is in is being run
+	conditions []bool
+}
+
+// VerifyInput runs the unlocking script of the input at inIndex followed by the locking script of prevOut,
+// the output it spends. If prevOut is P2SH the redeem script it hashes to is then run too.
+// Returns why the input can't spend prevOut, if it can't.
+func (tx *Transaction) VerifyInput(inIndex int, prevOut TxOutput) error {
+	scriptSig := tx.Vin[inIndex].ScriptSig
+	if len(scriptSig) > conf.SCRIPTmaxsize || len(prevOut.ScriptPubKey) > conf.SCRIPTmaxsize {
+		return errors.New("script is too long")
+	}
+	// Only data can go in an unlocking script, otherwise a third party could rewrite it without breaking it
+	if !scriptSig.IsPushOnly() {
+		return errors.New("unlocking script does more than push data")
+	}
+
+	engine := &scriptEngine{tx: tx, inIndex: inIndex, prevScript: prevOut.ScriptPubKey}
+	if err := engine.run(scriptSig); err != nil {
+		return fmt.Errorf("unlocking script: %v", err)
+	}
+	// The locking script of a P2SH output only checks the redeem script's hash, so keep the stack to run it against
+	unlocked := append([][]byte{}, engine.stack...)
+	if err := engine.run(prevOut.ScriptPubKey); err != nil {
+		return fmt.Errorf("locking script: %v", err)
+	}
+	if !engine.succeeded() {
+		return errors.New("scripts finished without leaving true on the stack")
+	}
+	if prevOut.ScriptPubKey.ScriptHash() == nil {
+		return nil
+	}
+
+	// The hash matched, so the redeem script is the last thing the unlocking script pushed
+	redeemScript := Script(unlocked[len(unlocked)-1])
+	engine.stack = unlocked[:len(unlocked)-1]
+	engine.prevScript = redeemScript
+	if err := engine.run(redeemScript); err != nil {
+		return fmt.Errorf("redeem script: %v", err)
+	}
+	if !engine.succeeded() {
+		return errors.New("redeem script finished without leaving true on the stack")
+	}
+	return nil
+}
+
+// run executes a script against the engine's stack
+func (e *scriptEngine) run(script Script) error {
+	ops, err := script.parse()
+	if err != nil {
+		return err
+	}
+	e.conditions = nil
+	for _, op := range ops {
+		if err := e.step(op); err != nil {
+			return err
+		}
+		if len(e.stack) > conf.SCRIPTmaxstacksize {
+			return errors.New("stack is too big")
+		}
+	}
+	if len(e.conditions) != 0 {
+		return errors.New("OP_IF without OP_ENDIF")
+	}
+	return nil
+}
+
+// succeeded returns whether the scripts run so far left true on top of the stack
+func (e *scriptEngine) succeeded() bool {
+	return len(e.stack) > 0 && asBool(e.stack[len(e.stack)-1])
+}
+
+// executing returns whether every OP_IF/OP_NOTIF block the engine is in is being run
+func (e *scriptEngine) executing() bool {
+	for _, condition := range e.conditions {
+		if !condition {
+			return false
+		}
+	}
+	return true
+}
+
+// step executes a single op
+func (e *scriptEngine) step(op scriptOp) error {
+	if len(op.Data) > conf.SCRIPTmaxelementsize {
+		return fmt.Errorf("push of %d bytes is too big", len(op.Data))
+	}
+
+	// Conditionals are tracked even in blocks that aren't being run so the right OP_ENDIF closes them
+	switch op.Op {
+	case OpIf, OpNotIf:
+		condition := false
+		if e.executing() {
+			top, err := e.pop()
+			if err != nil {
+				return err
+			}
+			condition = asBool(top) == (op.Op == OpIf)
+		}
+		e.conditions = append(e.conditions, condition)
+		return nil
+	case OpElse:
+		if len(e.conditions) == 0 {
+			return errors.New("OP_ELSE without OP_IF")
+		}
+		e.conditions[len(e.conditions)-1] = !e.conditions[len(e.conditions)-1]
+		return nil
+	case OpEndIf:
+		if len(e.conditions) == 0 {
+			return errors.New("OP_ENDIF without OP_IF")
+		}
+		e.conditions = e.conditions[:len(e.conditions)-1]
+		return nil
+	}
+	if !e.executing() {
+		return nil
+	}
+
+	switch {
+	case op.Op <= OpPushData2:
+		e.push(op.Data)
+		return nil
+	case op.Op == Op1Negate:
+		e.push(encodeScriptNum(-1))
+		return nil
+	case op.Op >= Op1 && op.Op <= Op16:
+		e.push(encodeScriptNum(int64(op.Op - Op1 + 1)))
+		return nil
+	}
+
+	switch op.Op {
+	case OpNop:
+	case OpVerify:
+		return e.verify("OP_VERIFY")
+	case OpReturn:
+		return errors.New("OP_RETURN")
+
+	case OpDrop:
+		_, err := e.pop()
+		return err
+	case OpDup:
+		top, err := e.peek(0)
+		if err != nil {
+			return err
+		}
+		e.push(top)
+	case OpSwap:
+		a, err := e.pop()
+		if err != nil {
+			return err
+		}
+		b, err := e.pop()
+		if err != nil {
+			return err
+		}
+		e.push(a)
+		e.push(b)
+	case OpSize:
+		top, err := e.peek(0)
+		if err != nil {
+			return err
+		}
+		e.push(encodeScriptNum(int64(len(top))))
+
+	case OpEqual, OpEqualVerify:
+		a, err := e.pop()
+		if err != nil {
+			return err
+		}
+		b, err := e.pop()
+		if err != nil {
+			return err
+		}
+		e.pushBool(bytes.Equal(a, b))
+		if op.Op == OpEqualVerify {
+			return e.verify("OP_EQUALVERIFY")
+		}
+
+	case OpSha256:
+		top, err := e.pop()
+		if err != nil {
+			return err
+		}
+		hash := sha256.Sum256(top)
+		e.push(hash[:])
+	case OpHash160:
+		top, err := e.pop()
+		if err != nil {
+			return err
+		}
+		e.push(wallet.HashPublicKey(top))
+
+	case OpCheckSig, OpCheckSigVerify:
+		pubKey, err := e.pop()
+		if err != nil {
+			return err
+		}
+		signature, err := e.pop()
+		if err != nil {
+			return err
+		}
+		valid, err := e.checkSignature(signature, pubKey)
+		if err != nil {
+			return err
+		}
+		e.pushBool(valid)
+		if op.Op == OpCheckSigVerify {
+			return e.verify("OP_CHECKSIGVERIFY")
+		}
+	case OpCheckMultiSig, OpCheckMultiSigVerify:
+		if err := e.checkMultiSig(); err != nil {
+			return err
+		}
+		if op.Op == OpCheckMultiSigVerify {
+			return e.verify("OP_CHECKMULTISIGVERIFY")
+		}
+
+	case OpCheckLockTimeVerify:
+		top, err := e.peek(0)
+		if err != nil {
+			return err
+		}
+		lockTime, err := decodeScriptNum(top, 5)
+		if err != nil {
+			return err
+		}
+		if lockTime < 0 || lockTime > int64(e.tx.LockTime) {
+			return fmt.Errorf("tx lock time %d is before %d", e.tx.LockTime, lockTime)
+		}
+
+	default:
+		return fmt.Errorf("unknown opcode %02x", op.Op)
+	}
+	return nil
+}
+
+// checkMultiSig pops the operands of OP_CHECKMULTISIG and pushes whether the signatures are valid.
+// Signatures have to be in the same order as the public keys they were made with.
+func (e *scriptEngine) checkMultiSig() error {
+	pubKeys, err := e.popList(conf.SCRIPTmaxpubkeys)
+	if err != nil {
+		return err
+	}
+	signatures, err := e.popList(len(pubKeys))
+	if err != nil {
+		return err
+	}
+
+	valid := true
+	for _, signature := range signatures {
+		// Skip keys until one matches the signature; if none is left it fails
+		for len(pubKeys) > 0 {
+			matched, err := e.checkSignature(signature, pubKeys[0])
+			if err != nil {
+				return err
+			}
+			if matched {
+				break
+			}
+			pubKeys = pubKeys[1:]
+		}
+		if len(pubKeys) == 0 {
+			valid = false
+			break
+		}
+		pubKeys = pubKeys[1:]
+	}
+	e.pushBool(valid)
+	return nil
+}
+
+// checkSignature returns whether signature, a DER signature followed by its sighash type, was made over the input's
+// signature hash for that type with the private key of pubKey, or an error if signature isn't canonically encoded
+func (e *scriptEngine) checkSignature(signature, pubKey []byte) (bool, error) {
+	if len(signature) == 0 {
+		return false, nil
+	}
+	hash, err := e.tx.SignatureHash(e.inIndex, e.prevScript, SigHashType(signature[len(signature)-1]))
+	if err != nil {
+		return false, err
+	}
+	return verifySignature(signature[:len(signature)-1], pubKey, hash)
+}
+
+// push puts an item on top of the stack
+func (e *scriptEngine) push(data []byte) {
+	e.stack = append(e.stack, data)
+}
+
+// pushBool pushes 1 for true or an empty item for false
+func (e *scriptEngine) pushBool(value bool) {
+	if value {
+		e.push([]byte{1})
+	} else {
+		e.push([]byte{})
+	}
+}
+
+// peek returns the item depth places below the top of the stack
+func (e *scriptEngine) peek(depth int) ([]byte, error) {
+	if depth >= len(e.stack) {
+		return nil, errors.New("stack is empty")
+	}
+	return e.stack[len(e.stack)-1-depth], nil
+}
+
+// pop removes and returns the top item of the stack
+func (e *scriptEngine) pop() ([]byte, error) {
+	top, err := e.peek(0)
+	if err != nil {
+		return nil, err
+	}
+	e.stack = e.stack[:len(e.stack)-1]
+	return top, nil
+}
+
+// popList pops a count of at most max followed by that many items, returned in the order they were pushed
+func (e *scriptEngine) popList(max int) ([][]byte, error) {
+	top, err := e.pop()
+	if err != nil {
+		return nil, err
+	}
+	count, err := decodeScriptNum(top, 4)
+	if err != nil {
+		return nil, err
+	}
+	if count < 0 || count > int64(max) {
+		return nil, fmt.Errorf("count %d is out of range", count)
+	}
+	items := make([][]byte, count)
+	for i := len(items) - 1; i >= 0; i-- {
+		if items[i], err = e.pop(); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// verify pops the top item and fails unless it is true
+func (e *scriptEngine) verify(name string) error {
+	top, err := e.pop()
+	if err != nil {
+		return err
+	}
+	if !asBool(top) {
+		return fmt.Errorf("%s failed", name)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"math/big"
+	"strings"
+	"testing"
+
+	conf "github.com/casalettoj/chroma/constants"
+	wallet "github.com/casalettoj/chroma/wallet"
+)
+
+// repeatOp returns a script of n copies of op
+func repeatOp(op byte, n int) Script {
+	return Script(bytes.Repeat([]byte{op}, n))
+}
+
+func TestVerifyInput(t *testing.T) {
+	key := testKey(elliptic.P256(), big.NewInt(0x1234))
+	pubKey := wallet.MarshalPublicKey(wallet.P256, key.X, key.Y)
+	otherKey := testKey(elliptic.P256(), big.NewInt(0x5678))
+	otherPubKey := wallet.MarshalPublicKey(wallet.P256, otherKey.X, otherKey.Y)
+
+	p2pkh := NewP2PKHScript(wallet.HashPublicKey(pubKey))
+	redeemScript := Script{}.AddData(pubKey).AddOp(OpCheckSig)
+	p2sh := NewP2SHScript(wallet.HashPublicKey(redeemScript))
+	const lockHeight = 100
+	cltv := Script{}.AddInt(lockHeight).AddOp(OpCheckLockTimeVerify).AddOp(OpDrop).AddOp(Op1)
+
+	// sign returns the unlocking signature of key for a tx with the given lock time spending an output locked by script
+	sign := func(key *big.Int, script Script, lockTime int) []byte {
+		tx := Transaction{Vin: []TxInput{{TxID: []byte("prev"), Vout: 0}}, LockTime: lockTime}
+		signature, err := tx.signInput(0, script, testKey(elliptic.P256(), key), SigHashAll)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signature
+	}
+
+	for _, test := range []struct {
+		name      string
+		scriptSig Script
+		lock      Script
+		lockTime  int
+		// wantErr is part of the error the input fails with, or empty if it should be valid
+		wantErr string
+	}{
+		{"p2pkh", NewP2PKHScriptSig(sign(key.D, p2pkh, 0), pubKey), p2pkh, 0, ""},
+		{"p2pkh with another key", NewP2PKHScriptSig(sign(otherKey.D, p2pkh, 0), otherPubKey), p2pkh, 0, "OP_EQUALVERIFY failed"},
+		{"p2pkh signed by another key", NewP2PKHScriptSig(sign(otherKey.D, p2pkh, 0), pubKey), p2pkh, 0, "without leaving true"},
+		{"p2pkh signature for another tx", NewP2PKHScriptSig(sign(key.D, p2pkh, 1), pubKey), p2pkh, 0, "without leaving true"},
+		{"p2pkh without a signature", Script{}.AddData(pubKey), p2pkh, 0, "stack is empty"},
+
+		{"p2sh", Script{}.AddData(sign(key.D, redeemScript, 0)).AddData(redeemScript), p2sh, 0, ""},
+		{"p2sh with another redeem script", Script{}.AddData(sign(key.D, redeemScript, 0)).AddData(Script{}.AddOp(Op1)), p2sh, 0, "without leaving true"},
+		{"p2sh redeem script failing", Script{}.AddData(sign(otherKey.D, redeemScript, 0)).AddData(redeemScript), p2sh, 0, "redeem script finished"},
+
+		{"cltv before the lock height", Script{}, cltv, lockHeight - 1, "lock time 99 is before 100"},
+		{"cltv at the lock height", Script{}, cltv, lockHeight, ""},
+		{"cltv after the lock height", Script{}, cltv, lockHeight + 1, ""},
+		{"cltv with a negative height", Script{}, Script{}.AddInt(-1).AddOp(OpCheckLockTimeVerify), lockHeight, "is before -1"},
+		{"cltv with an empty stack", Script{}, Script{OpCheckLockTimeVerify}, lockHeight, "stack is empty"},
+
+		{"dup of an empty stack", Script{}, Script{OpDup}, 0, "stack is empty"},
+		{"equal with one item", Script{}.AddOp(Op1), Script{OpEqual}, 0, "stack is empty"},
+		{"multisig missing its signatures", Script{}, NewMultiSigScript(1, [][]byte{pubKey}), 0, "stack is empty"},
+		{"empty scripts", Script{}, Script{}, 0, "without leaving true"},
+
+		// Opcodes Bitcoin disabled were never implemented, so they fail as unknown ones do
+		{"disabled OP_CAT", Script{}, Script{Op1, 0x7e}, 0, "unknown opcode 7e"},
+		{"disabled OP_MUL", Script{}, Script{Op1, 0x95}, 0, "unknown opcode 95"},
+		{"unknown opcode", Script{}, Script{Op1, 0xba}, 0, "unknown opcode ba"},
+		{"unknown opcode in the unlocking script", Script{0xff}, Script{Op1}, 0, "does more than push data"},
+		{"OP_RETURN", Script{}, Script{Op1, OpReturn}, 0, "OP_RETURN"},
+
+		{"largest locking script", Script{}, repeatOp(OpNop, conf.SCRIPTmaxsize-1).AddOp(Op1), 0, ""},
+		{"locking script too long", Script{}, repeatOp(OpNop, conf.SCRIPTmaxsize).AddOp(Op1), 0, "too long"},
+		{"unlocking script too long", repeatOp(Op0, conf.SCRIPTmaxsize+1), Script{Op1}, 0, "too long"},
+		{"largest push", Script{}.AddData(make([]byte, conf.SCRIPTmaxelementsize)), Script{OpDrop, Op1}, 0, ""},
+		{"push too big", Script{}.AddData(make([]byte, conf.SCRIPTmaxelementsize+1)), Script{OpDrop, Op1}, 0, "push of 521 bytes"},
+		{"fullest stack", repeatOp(Op1, conf.SCRIPTmaxstacksize), Script{}, 0, ""},
+		{"stack too big", repeatOp(Op1, conf.SCRIPTmaxstacksize+1), Script{}, 0, "stack is too big"},
+		{"push running past the end", Script{}, Script{0x05, 0x01}, 0, "runs past the end"},
+	} {
+		tx := Transaction{Vin: []TxInput{{TxID: []byte("prev"), Vout: 0, ScriptSig: test.scriptSig}}, LockTime: test.lockTime}
+		err := tx.VerifyInput(0, TxOutput{Value: 1, ScriptPubKey: test.lock})
+		switch {
+		case test.wantErr == "" && err != nil:
+			t.Errorf("%s: %v", test.name, err)
+		case test.wantErr != "" && err == nil:
+			t.Errorf("%s: was accepted", test.name)
+		case test.wantErr != "" && !strings.Contains(err.Error(), test.wantErr):
+			t.Errorf("%s: got error %q, want one containing %q", test.name, err, test.wantErr)
+		}
+	}
+}
@@ -2,7 +2,6 @@ package blockchain
 
 import (
 	conf "github.com/casalettoj/chroma/constants"
-	util "github.com/casalettoj/chroma/utils"
 	bolt "github.com/coreos/bbolt"
 )
 
@@ -13,10 +12,13 @@ type Iterator struct {
 }
 
 // Next returns the current hash and decrements the current block hash to its previous
-func (i *Iterator) Next() *Block {
-	block := i.Peek()
+func (i *Iterator) Next() (*Block, error) {
+	block, err := i.Peek()
+	if err != nil {
+		return nil, err
+	}
 	i.CurrentHash = block.PrevHash
-	return block
+	return block, nil
 }
 
 // IsGenesisBlock returns whether the current hash points to the genesis block
@@ -25,13 +27,17 @@ func (i *Iterator) IsGenesisBlock() bool {
 }
 
 // Peek returns the block at the current hash of the iterator
-func (i *Iterator) Peek() *Block {
+func (i *Iterator) Peek() (*Block, error) {
 	var block *Block
-	util.CheckAnxiety(i.DB.View(func(tx *bolt.Tx) error {
+	err := i.DB.View(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(conf.DBblocksbucket))
 		encodedBlock := bucket.Get(i.CurrentHash)
-		block = DeserializeBlock(encodedBlock)
-		return nil
-	}))
-	return block
+		if encodedBlock == nil {
+			return ErrBlockNotFound
+		}
+		var err error
+		block, err = DeserializeBlock(encodedBlock)
+		return err
+	})
+	return block, err
 }
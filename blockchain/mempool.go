@@ -0,0 +1,191 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Mempool holds verified transactions that haven't made it into a block yet, keyed by hex TxID.
+// It isn't safe for concurrent use; callers (e.g. a network node) guard it themselves.
+type Mempool struct {
+	entries map[string]*mempoolEntry
+	// spent maps every outpoint used by a pooled tx to the ID of the tx spending it
+	spent map[string]string
+}
+
+// mempoolEntry is a pooled transaction along with when it arrived, its fee and its serialized size in bytes
+type mempoolEntry struct {
+	Tx    Transaction
+	Added time.Time
+	Fee   int
+	Size  int
+}
+
+// NewMempool returns an empty pool
+func NewMempool() *Mempool {
+	return &Mempool{entries: make(map[string]*mempoolEntry), spent: make(map[string]string)}
+}
+
+// outpoint returns the key an output is tracked under: hex TxID and output index
+func outpoint(txID []byte, vout int) string {
+	return fmt.Sprintf("%x:%d", txID, vout)
+}
+
+// Size returns the number of pooled transactions
+func (mp *Mempool) Size() int {
+	return len(mp.entries)
+}
+
+// Has returns whether the transaction with the given ID is pooled
+func (mp *Mempool) Has(txID []byte) bool {
+	_, ok := mp.entries[hex.EncodeToString(txID)]
+	return ok
+}
+
+// Spends returns whether a pooled transaction spends the output at index vout of the transaction txID
+func (mp *Mempool) Spends(txID []byte, vout int) bool {
+	_, ok := mp.spent[outpoint(txID, vout)]
+	return ok
+}
+
+// Get returns the pooled transaction with the given ID
+func (mp *Mempool) Get(txID []byte) (Transaction, bool) {
+	entry, ok := mp.entries[hex.EncodeToString(txID)]
+	if !ok {
+		return Transaction{}, false
+	}
+	return entry.Tx, true
+}
+
+// Add checks a transaction against the chain's UTXO set and the rest of the pool and pools it.
+// Returns why the transaction was rejected, if it was.
+func (mp *Mempool) Add(bc *Blockchain, tx *Transaction) error {
+	txID := hex.EncodeToString(tx.ID)
+	if _, ok := mp.entries[txID]; ok {
+		return errors.New("tx already pooled")
+	}
+	if tx.IsCoinbaseTx() {
+		return errors.New("coinbase tx can only be mined")
+	}
+	for _, in := range tx.Vin {
+		key := outpoint(in.TxID, in.Vout)
+		if spender, ok := mp.spent[key]; ok {
+			return fmt.Errorf("%s is already spent by pooled tx %s", key, spender)
+		}
+	}
+
+	totalOut, err := checkOutputs(bc.Network, tx)
+	if err != nil {
+		return fmt.Errorf("tx %v", err)
+	}
+	bestHeight, err := bc.GetBestHeight()
+	if err != nil {
+		return err
+	}
+	fee, err := checkInputs(bc.Network, tx, totalOut, newUTXOView(bc), bestHeight+1)
+	if err != nil {
+		return fmt.Errorf("tx %v", err)
+	}
+
+	mp.entries[txID] = &mempoolEntry{Tx: *tx, Added: time.Now(), Fee: fee, Size: len(tx.Serialize())}
+	for _, in := range tx.Vin {
+		mp.spent[outpoint(in.TxID, in.Vout)] = txID
+	}
+	return nil
+}
+
+// Remove drops the transaction with the given ID from the pool
+func (mp *Mempool) Remove(txID []byte) {
+	key := hex.EncodeToString(txID)
+	entry, ok := mp.entries[key]
+	if !ok {
+		return
+	}
+	for _, in := range entry.Tx.Vin {
+		delete(mp.spent, outpoint(in.TxID, in.Vout))
+	}
+	delete(mp.entries, key)
+}
+
+// RemoveBlock drops a newly connected block's transactions from the pool, along with any pooled
+// transactions that spend the same outputs and so can never be mined now.
+func (mp *Mempool) RemoveBlock(b *Block) {
+	for _, tx := range b.Transactions {
+		mp.Remove(tx.ID)
+		if tx.IsCoinbaseTx() {
+			continue
+		}
+		for _, in := range tx.Vin {
+			if spender, ok := mp.spent[outpoint(in.TxID, in.Vout)]; ok {
+				mp.Remove(mp.entries[spender].Tx.ID)
+			}
+		}
+	}
+}
+
+// Transactions returns every pooled transaction, oldest first
+func (mp *Mempool) Transactions() []Transaction {
+	entries := mp.sortedEntries(func(a, b *mempoolEntry) bool {
+		return a.Added.Before(b.Added)
+	})
+
+	var txs []Transaction
+	for _, entry := range entries {
+		txs = append(txs, entry.Tx)
+	}
+	return txs
+}
+
+// SelectForBlock returns up to max pooled transactions for the next block, highest fee per byte first,
+// along with the total fees they pay. Each is checked again at the next block's height, since the chain may have
+// moved since it was pooled: transactions whose inputs have been spent on chain, are coinbase outputs that are
+// immature again after a reorganization or whose lock time is out of reach again are dropped instead.
+func (mp *Mempool) SelectForBlock(bc *Blockchain, max int) (selected []*Transaction, fees int, err error) {
+	bestHeight, err := bc.GetBestHeight()
+	if err != nil {
+		return nil, 0, err
+	}
+	nextHeight := bestHeight + 1
+	entries := mp.sortedEntries(func(a, b *mempoolEntry) bool {
+		// Compare Fee/Size without dividing so small fees don't round to the same rate
+		if rateA, rateB := a.Fee*b.Size, b.Fee*a.Size; rateA != rateB {
+			return rateA > rateB
+		}
+		return a.Added.Before(b.Added)
+	})
+
+	view := newUTXOView(bc)
+	for _, entry := range entries {
+		if len(selected) >= max {
+			break
+		}
+		tx := entry.Tx
+		totalOut, err := checkOutputs(bc.Network, &tx)
+		if err == nil {
+			_, err = checkInputs(bc.Network, &tx, totalOut, view, nextHeight)
+		}
+		if err != nil {
+			mp.Remove(tx.ID)
+			continue
+		}
+		view.apply(&tx, nextHeight)
+		selected = append(selected, &tx)
+		fees += entry.Fee
+	}
+	return selected, fees, nil
+}
+
+// sortedEntries returns every pooled entry ordered by less
+func (mp *Mempool) sortedEntries(less func(a, b *mempoolEntry) bool) []*mempoolEntry {
+	var entries []*mempoolEntry
+	for _, entry := range mp.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return less(entries[i], entries[j])
+	})
+	return entries
+}
@@ -0,0 +1,186 @@
+package blockchain
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/casalettoj/chroma/wallet"
+)
+
+// newMempoolChain creates a test chain with seven mature coinbase txs paying the returned wallet
+func newMempoolChain(t *testing.T) (*Blockchain, *wallet.Wallet, []*Transaction) {
+	t.Helper()
+	bc, w := newTestChain(t)
+	genesis, err := bc.GetBlock(bc.Tip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks, err := bc.Generate(6, string(w.GetChromaAddress(bc.Network)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = bc.Generate(bc.Network.CoinbaseMaturity, string(wallet.NewWallet(wallet.P256).GetChromaAddress(bc.Network))); err != nil {
+		t.Fatal(err)
+	}
+	coinbases := []*Transaction{genesis.Transactions[0]}
+	for _, block := range blocks {
+		coinbases = append(coinbases, block.Transactions[0])
+	}
+	return bc, w, coinbases
+}
+
+// newSpend returns a tx signed by w spending the first output of each of prevTxs to a new address, leaving fee
+func newSpend(t *testing.T, bc *Blockchain, w *wallet.Wallet, fee int, prevTxs ...*Transaction) *Transaction {
+	t.Helper()
+	tx := &Transaction{}
+	total := 0
+	for _, prevTx := range prevTxs {
+		tx.Vin = append(tx.Vin, TxInput{TxID: prevTx.ID, Vout: 0})
+		total += prevTx.Vout[0].Value
+	}
+	out, err := NewUTXO(bc.Network, total-fee, string(wallet.NewWallet(wallet.P256).GetChromaAddress(bc.Network)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.Vout = []TxOutput{*out}
+	tx.ID = tx.Hash()
+	if _, err = bc.SignTransaction(tx, w, SigHashAll); err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+func TestMempoolDoubleSpends(t *testing.T) {
+	bc, w, coinbases := newMempoolChain(t)
+	to := string(wallet.NewWallet(wallet.P256).GetChromaAddress(bc.Network))
+	mp := NewMempool()
+
+	// An output spent on chain can't be spent again
+	if err := mineTx(bc, newSpend(t, bc, w, 1, coinbases[0]), to); err != nil {
+		t.Fatal(err)
+	}
+	if err := mp.Add(bc, newSpend(t, bc, w, 2, coinbases[0])); err == nil {
+		t.Error("tx spending an output spent on chain was pooled")
+	}
+	missing := newSpend(t, bc, w, 1, coinbases[1])
+	missing.Vin[0].Vout = 1
+	missing.ID = missing.ComputeID()
+	if err := mp.Add(bc, missing); err == nil {
+		t.Error("tx spending an output that doesn't exist was pooled")
+	}
+
+	// Nor can one a pooled tx spends, even alongside another output
+	first := newSpend(t, bc, w, 1, coinbases[1])
+	if err := mp.Add(bc, first); err != nil {
+		t.Fatal(err)
+	}
+	if err := mp.Add(bc, first); err == nil {
+		t.Error("the same tx was pooled twice")
+	}
+	for _, conflict := range []*Transaction{newSpend(t, bc, w, 2, coinbases[1]), newSpend(t, bc, w, 2, coinbases[2], coinbases[1])} {
+		if err := mp.Add(bc, conflict); err == nil {
+			t.Errorf("tx %x spending an output a pooled tx spends was pooled", conflict.ID)
+		}
+	}
+	if mp.Size() != 1 || !mp.Has(first.ID) || !mp.Spends(coinbases[1].ID, 0) || mp.Spends(coinbases[2].ID, 0) {
+		t.Errorf("pool holds %d txs, want just the first spend", mp.Size())
+	}
+
+	// Once the pooled tx is gone its output can be spent again
+	mp.Remove(first.ID)
+	if err := mp.Add(bc, newSpend(t, bc, w, 2, coinbases[1])); err != nil {
+		t.Errorf("spending an output after removing its pooled spender: %v", err)
+	}
+}
+
+func TestMempoolSelectForBlock(t *testing.T) {
+	bc, w, coinbases := newMempoolChain(t)
+	mp := NewMempool()
+	low := newSpend(t, bc, w, 1, coinbases[0])
+	high := newSpend(t, bc, w, 6, coinbases[1])
+	mid := newSpend(t, bc, w, 4, coinbases[2])
+	// A bigger fee than mid's, but paid over two inputs it's a lower rate
+	wide := newSpend(t, bc, w, 5, coinbases[3], coinbases[4])
+	if 5*len(mid.Serialize()) >= 4*len(wide.Serialize()) {
+		t.Fatal("the two input tx doesn't pay a lower rate than the one input tx")
+	}
+	for _, tx := range []*Transaction{low, wide, high, mid} {
+		if err := mp.Add(bc, tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		max  int
+		want []*Transaction
+		fees int
+	}{
+		{10, []*Transaction{high, mid, wide, low}, 16},
+		{2, []*Transaction{high, mid}, 10},
+		{0, nil, 0},
+	} {
+		selected, fees, err := mp.SelectForBlock(bc, test.max)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(selected) != len(test.want) || fees != test.fees {
+			t.Errorf("max %d: selected %d txs paying %d, want %d paying %d", test.max, len(selected), fees, len(test.want), test.fees)
+			continue
+		}
+		for i, tx := range selected {
+			if !bytes.Equal(tx.ID, test.want[i].ID) {
+				t.Errorf("max %d: tx %d is %x, want %x", test.max, i, tx.ID, test.want[i].ID)
+			}
+		}
+	}
+
+	// A pooled tx whose output was spent on chain since is dropped instead of selected
+	if err := mineTx(bc, newSpend(t, bc, w, 2, coinbases[0]), string(w.GetChromaAddress(bc.Network))); err != nil {
+		t.Fatal(err)
+	}
+	selected, fees, err := mp.SelectForBlock(bc, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(selected) != 3 || fees != 15 || mp.Has(low.ID) {
+		t.Errorf("selected %d txs paying %d with the spent one still pooled %v, want 3 paying 15", len(selected), fees, mp.Has(low.ID))
+	}
+}
+
+func TestMempoolRemoveBlock(t *testing.T) {
+	bc, w, coinbases := newMempoolChain(t)
+	mp := NewMempool()
+	confirmed := newSpend(t, bc, w, 1, coinbases[0])
+	conflicting := newSpend(t, bc, w, 1, coinbases[1])
+	unrelated := newSpend(t, bc, w, 1, coinbases[2])
+	for _, tx := range []*Transaction{confirmed, conflicting, unrelated} {
+		if err := mp.Add(bc, tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The block confirms one pooled tx and spends the output of another in a tx that was never pooled
+	height, err := bc.GetBestHeight()
+	if err != nil {
+		t.Fatal(err)
+	}
+	coinbaseTx, err := NewCoinbaseTx(bc.Network, string(w.GetChromaAddress(bc.Network)), "", height+1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := bc.MineBlock([]*Transaction{coinbaseTx, confirmed, newSpend(t, bc, w, 2, coinbases[1])})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mp.RemoveBlock(block)
+
+	if mp.Has(confirmed.ID) || mp.Has(conflicting.ID) {
+		t.Error("confirmed or conflicting tx is still pooled")
+	}
+	if !mp.Has(unrelated.ID) || mp.Size() != 1 {
+		t.Errorf("pool holds %d txs, want just the unrelated one", mp.Size())
+	}
+	if mp.Spends(coinbases[0].ID, 0) || mp.Spends(coinbases[1].ID, 0) {
+		t.Error("pool still tracks outputs of removed txs as spent")
+	}
+}
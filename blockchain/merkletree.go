@@ -0,0 +1,96 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+)
+
+// MerkleTree is a binary hash tree built over a list of leaves (a block's TxIDs), stored level by level
+// from the leaves up to the root. A level with an odd number of hashes pairs its last hash with itself.
+type MerkleTree struct {
+	Levels [][][]byte
+}
+
+// MerkleProof is the path from a leaf to the root: the sibling hash at every level, bottom up.
+// Index is the leaf's position, which says on which side each sibling goes.
+type MerkleProof struct {
+	Leaf     []byte
+	Index    int
+	Siblings [][]byte
+}
+
+// hashMerkleNodes returns the parent hash of two nodes
+func hashMerkleNodes(left, right []byte) []byte {
+	hash := sha256.Sum256(append(append([]byte{}, left...), right...))
+	return hash[:]
+}
+
+// NewMerkleTree builds a tree over the given leaves
+func NewMerkleTree(leaves [][]byte) *MerkleTree {
+	if len(leaves) == 0 {
+		emptyHash := sha256.Sum256([]byte{})
+		return &MerkleTree{Levels: [][][]byte{{emptyHash[:]}}}
+	}
+
+	level := append([][]byte{}, leaves...)
+	tree := &MerkleTree{Levels: [][][]byte{level}}
+	for len(level) > 1 {
+		var parents [][]byte
+		for i := 0; i < len(level); i += 2 {
+			right := level[i]
+			if i+1 < len(level) {
+				right = level[i+1]
+			}
+			parents = append(parents, hashMerkleNodes(level[i], right))
+		}
+		tree.Levels = append(tree.Levels, parents)
+		level = parents
+	}
+	return tree
+}
+
+// RootHash returns the hash at the top of the tree
+func (mt *MerkleTree) RootHash() []byte {
+	return mt.Levels[len(mt.Levels)-1][0]
+}
+
+// Proof returns the inclusion proof for a leaf
+func (mt *MerkleTree) Proof(leaf []byte) (*MerkleProof, error) {
+	index := -1
+	for i, hash := range mt.Levels[0] {
+		if bytes.Compare(hash, leaf) == 0 {
+			index = i
+			break
+		}
+	}
+	if index == -1 {
+		return nil, errors.New("leaf not in merkle tree")
+	}
+
+	proof := &MerkleProof{Leaf: leaf, Index: index}
+	for _, level := range mt.Levels[:len(mt.Levels)-1] {
+		sibling := index ^ 1
+		if sibling >= len(level) {
+			sibling = index
+		}
+		proof.Siblings = append(proof.Siblings, level[sibling])
+		index /= 2
+	}
+	return proof, nil
+}
+
+// Verify returns whether the proof hashes its leaf up to the given root
+func (mp *MerkleProof) Verify(root []byte) bool {
+	hash := mp.Leaf
+	index := mp.Index
+	for _, sibling := range mp.Siblings {
+		if index%2 == 0 {
+			hash = hashMerkleNodes(hash, sibling)
+		} else {
+			hash = hashMerkleNodes(sibling, hash)
+		}
+		index /= 2
+	}
+	return index == 0 && bytes.Compare(hash, root) == 0
+}
@@ -0,0 +1,102 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"testing"
+)
+
+// testLeaves returns n distinct leaf hashes
+func testLeaves(n int) [][]byte {
+	var leaves [][]byte
+	for i := 0; i < n; i++ {
+		hash := sha256.Sum256([]byte(fmt.Sprintf("tx %d", i)))
+		leaves = append(leaves, hash[:])
+	}
+	return leaves
+}
+
+func TestMerkleRoot(t *testing.T) {
+	l := testLeaves(5)
+	h := hashMerkleNodes
+	empty := sha256.Sum256([]byte{})
+	for _, test := range []struct {
+		name   string
+		leaves [][]byte
+		root   []byte
+	}{
+		{"no txs", nil, empty[:]},
+		{"single tx", l[:1], l[0]},
+		{"two txs", l[:2], h(l[0], l[1])},
+		// The odd leaf out is paired with itself
+		{"three txs", l[:3], h(h(l[0], l[1]), h(l[2], l[2]))},
+		{"four txs", l[:4], h(h(l[0], l[1]), h(l[2], l[3]))},
+		// The duplicated leaf's parent is odd one out again a level up
+		{"five txs", l, h(h(h(l[0], l[1]), h(l[2], l[3])), h(h(l[4], l[4]), h(l[4], l[4])))},
+	} {
+		if root := NewMerkleTree(test.leaves).RootHash(); !bytes.Equal(root, test.root) {
+			t.Errorf("%s: got root %x, want %x", test.name, root, test.root)
+		}
+	}
+}
+
+func TestMerkleProof(t *testing.T) {
+	for n := 1; n <= 9; n++ {
+		leaves := testLeaves(n)
+		tree := NewMerkleTree(leaves)
+		root := tree.RootHash()
+		for i, leaf := range leaves {
+			proof, err := tree.Proof(leaf)
+			if err != nil {
+				t.Fatalf("%d txs, leaf %d: %v", n, i, err)
+			}
+			if proof.Index != i || !proof.Verify(root) {
+				t.Errorf("%d txs, leaf %d: proof doesn't verify", n, i)
+			}
+			if n == 1 && len(proof.Siblings) != 0 {
+				t.Errorf("proof of a single tx has %d siblings", len(proof.Siblings))
+			}
+
+			for j := range proof.Siblings {
+				tampered := *proof
+				tampered.Siblings = append([][]byte{}, proof.Siblings...)
+				tampered.Siblings[j] = append([]byte{}, proof.Siblings[j]...)
+				tampered.Siblings[j][0] ^= 1
+				if tampered.Verify(root) {
+					t.Errorf("%d txs, leaf %d: proof with sibling %d tampered verifies", n, i, j)
+				}
+			}
+			tampered := *proof
+			tampered.Leaf = leaves[(i+1)%n]
+			if n > 1 && tampered.Verify(root) {
+				t.Errorf("%d txs, leaf %d: proof verifies another leaf", n, i)
+			}
+			// Past the last leaf the index is out of the tree
+			tampered = *proof
+			tampered.Index = i + 1<<uint(len(proof.Siblings))
+			if tampered.Verify(root) {
+				t.Errorf("%d txs, leaf %d: proof verifies at index %d", n, i, tampered.Index)
+			}
+		}
+	}
+
+	// Moving a leaf to the other side of its sibling gives another root
+	leaves := testLeaves(4)
+	tree := NewMerkleTree(leaves)
+	proof, err := tree.Proof(leaves[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof.Index = 1
+	if proof.Verify(tree.RootHash()) {
+		t.Error("proof verifies with the leaf on the wrong side")
+	}
+
+	if _, err = tree.Proof(testLeaves(5)[4]); err == nil {
+		t.Error("got a proof for a leaf not in the tree")
+	}
+	if proof, err = tree.Proof(leaves[0]); err != nil || proof.Verify(NewMerkleTree(leaves[:3]).RootHash()) {
+		t.Errorf("proof verifies against another tree's root (%v)", err)
+	}
+}
@@ -0,0 +1,227 @@
+package blockchain
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	conf "github.com/casalettoj/chroma/constants"
+	util "github.com/casalettoj/chroma/utils"
+)
+
+// Miner finds the proof of work for blocks, splitting the nonces to try among several goroutines
+type Miner struct {
+	// Workers is the number of goroutines searching for a nonce, or 0 for one per CPU
+	Workers int
+	// OnHashrate, if set, is called every POWhashrateseconds while mining with the hashes tried per second since the
+	// last call. Calls come from a single goroutine and are over by the time Mine returns.
+	OnHashrate func(hashesPerSecond float64)
+
+	mutex sync.Mutex
+	stats MiningStats
+}
+
+// MiningStats adds up the work a Miner has done
+type MiningStats struct {
+	// Blocks is the number of blocks mined
+	Blocks int
+	// Hashes is the number of hashes tried, including for blocks that were abandoned
+	Hashes int64
+	// Duration is the time spent mining
+	Duration time.Duration
+	// LastAttempts is the number of hashes tried for the last block mined, and LastDuration the time it took
+	LastAttempts int64
+	LastDuration time.Duration
+}
+
+// HashesPerSecond returns the average hashrate over all the time spent mining
+func (s MiningStats) HashesPerSecond() float64 {
+	if s.Duration <= 0 {
+		return 0
+	}
+	return float64(s.Hashes) / s.Duration.Seconds()
+}
+
+// AttemptsPerBlock returns the average number of hashes tried for each block mined
+func (s MiningStats) AttemptsPerBlock() float64 {
+	if s.Blocks == 0 {
+		return 0
+	}
+	return float64(s.Hashes) / float64(s.Blocks)
+}
+
+// TimeToBlock returns the average time spent mining each block
+func (s MiningStats) TimeToBlock() time.Duration {
+	if s.Blocks == 0 {
+		return 0
+	}
+	return s.Duration / time.Duration(s.Blocks)
+}
+
+// DefaultMiner mines the blocks of NewBlock and MineBlock, using every CPU and reporting nothing
+var DefaultMiner = &Miner{}
+
+// Stats returns what the miner has done so far
+func (m *Miner) Stats() MiningStats {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.stats
+}
+
+// Mine searches for a nonce that gives the block a hash below its target, then sets the block's Nonce and Hash.
+// Once every nonce up to POWmaxnonce has been tried the header is rolled (see rollHeader) and the search starts over.
+// Returns ctx's error if it's cancelled first, e.g. because a block arrived from a peer and this one is stale.
+// Either way the hashes tried and the time taken are added to the miner's stats.
+// Returns an error wrapping ErrInvalidBlock without trying any if the block's bits give no target to mine below.
+func (m *Miner) Mine(ctx context.Context, block *Block) error {
+	if CompactToTarget(block.Bits).Sign() <= 0 {
+		return fmt.Errorf("%w: bits %08x give no target to mine below", ErrInvalidBlock, block.Bits)
+	}
+	workers := m.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	var hashes int64
+	if m.OnHashrate != nil {
+		stop := make(chan struct{})
+		var reporter sync.WaitGroup
+		reporter.Add(1)
+		go func() {
+			defer reporter.Done()
+			m.reportHashrate(&hashes, stop)
+		}()
+		defer func() {
+			close(stop)
+			reporter.Wait()
+		}()
+	}
+
+	start := time.Now()
+	err := mine(ctx, block, workers, &hashes)
+	m.record(atomic.LoadInt64(&hashes), time.Since(start), err == nil)
+	return err
+}
+
+// mine does the searching for Mine, counting the hashes tried in hashes
+func mine(ctx context.Context, block *Block, workers int, hashes *int64) error {
+	var coinbaseData Script
+	if len(block.Transactions) > 0 && block.Transactions[0].IsCoinbaseTx() {
+		coinbaseData = append(Script{}, block.Transactions[0].Vin[0].ScriptSig...)
+	}
+	for extraNonce := 0; ; {
+		nonce, hash, found := searchNonces(ctx, NewProofOfWork(block), workers, hashes)
+		if found {
+			block.Nonce = nonce
+			block.Hash = hash
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		extraNonce = rollHeader(block, coinbaseData, extraNonce)
+	}
+}
+
+// record adds a call to Mine to the stats
+func (m *Miner) record(hashes int64, duration time.Duration, mined bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.stats.Hashes += hashes
+	m.stats.Duration += duration
+	if mined {
+		m.stats.Blocks++
+		m.stats.LastAttempts = hashes
+		m.stats.LastDuration = duration
+	}
+}
+
+// searchNonces tries the nonces up to POWmaxnonce on the given number of goroutines, each claiming POWnoncebatch
+// of them at a time, until one gives a hash below the target, they run out or ctx is cancelled.
+// Every hash tried is counted in hashes.
+func searchNonces(ctx context.Context, pow *ProofOfWork, workers int, hashes *int64) (nonce int, hash []byte, found bool) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	header := pow.prepareHeader()
+
+	var next int64
+	var once sync.Once
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data := make([]byte, len(header)+8)
+			copy(data, header)
+			var hashInt big.Int
+			for ctx.Err() == nil {
+				start := atomic.AddInt64(&next, conf.POWnoncebatch) - conf.POWnoncebatch
+				if start > conf.POWmaxnonce {
+					return
+				}
+				end := start + conf.POWnoncebatch
+				if end > conf.POWmaxnonce+1 {
+					end = conf.POWmaxnonce + 1
+				}
+				for n := start; n < end; n++ {
+					// The same big endian encoding PrepareData gives the nonce
+					binary.BigEndian.PutUint64(data[len(header):], uint64(n))
+					sum := sha256.Sum256(data)
+					if hashInt.SetBytes(sum[:]).Cmp(pow.target) == -1 {
+						atomic.AddInt64(hashes, n-start+1)
+						once.Do(func() {
+							nonce, hash, found = int(n), sum[:], true
+							cancel()
+						})
+						return
+					}
+				}
+				atomic.AddInt64(hashes, end-start)
+			}
+		}()
+	}
+	wg.Wait()
+	return nonce, hash, found
+}
+
+// rollHeader gives a block whose nonces have run out a new header to search: the timestamp moves up to the current
+// time or, if it's already there, the extra nonce appended to the coinbase tx's original data is bumped, changing
+// the Merkle root. A block without a coinbase tx has its timestamp bumped instead. Returns the extra nonce in use.
+func rollHeader(block *Block, coinbaseData Script, extraNonce int) int {
+	if now := time.Now().Unix(); now > block.Timestamp {
+		block.Timestamp = now
+		return extraNonce
+	}
+	if coinbaseData == nil {
+		block.Timestamp++
+		return extraNonce
+	}
+	extraNonce++
+	coinbase := block.Transactions[0]
+	coinbase.Vin[0].ScriptSig = append(append(Script{}, coinbaseData...), util.Int64ToByteArray(int64(extraNonce))...)
+	coinbase.ID = coinbase.Hash()
+	return extraNonce
+}
+
+// reportHashrate passes the hashrate to OnHashrate every POWhashrateseconds until stop is closed
+func (m *Miner) reportHashrate(hashes *int64, stop <-chan struct{}) {
+	ticker := time.NewTicker(conf.POWhashrateseconds * time.Second)
+	defer ticker.Stop()
+	last, lastTime := int64(0), time.Now()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			count := atomic.LoadInt64(hashes)
+			m.OnHashrate(float64(count-last) / now.Sub(lastTime).Seconds())
+			last, lastTime = count, now
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package blockchain
+
+import (
+	"math/big"
+
+	"github.com/casalettoj/chroma/config"
+)
+
+// MiningInfo describes the state of mining on a chain and, from a mining node, of its miner
+type MiningInfo struct {
+	Height int
+	// Bits is the difficulty the next block must be mined at, and Difficulty how many times harder its target is
+	// than the easiest one
+	Bits       uint32
+	Difficulty float64
+	// NetworkHashrate is the estimated number of hashes per second spent mining the chain
+	NetworkHashrate float64
+	// Miner holds a node's mining stats, nil if it isn't mining
+	Miner *MiningStats
+}
+
+// GetMiningInfo returns the mining info of the chain, estimating the network hashrate over the given number
+// of most recent blocks
+func (bc *Blockchain) GetMiningInfo(blocks int) (MiningInfo, error) {
+	tip, err := bc.GetBlock(bc.Tip)
+	if err != nil {
+		return MiningInfo{}, err
+	}
+	bits, err := bc.GetNextBits(&tip)
+	if err != nil {
+		return MiningInfo{}, err
+	}
+	hashrate, err := bc.EstimateNetworkHashrate(blocks)
+	if err != nil {
+		return MiningInfo{}, err
+	}
+	return MiningInfo{Height: tip.Height, Bits: bits, Difficulty: Difficulty(bc.Network, bits), NetworkHashrate: hashrate}, nil
+}
+
+// Difficulty returns how many times harder the target given by bits is to reach than the network's PowLimit
+func Difficulty(network *config.Network, bits uint32) float64 {
+	target := CompactToTarget(bits)
+	if target.Sign() <= 0 {
+		return 0
+	}
+	difficulty, _ := new(big.Float).Quo(new(big.Float).SetInt(PowLimit(network)), new(big.Float).SetInt(target)).Float64()
+	return difficulty
+}
+
+// EstimateNetworkHashrate estimates the hashes per second spent mining the chain from the work the given number of
+// most recent blocks needed (see BlockWork) and the time between their timestamps. Returns 0 if there are too few
+// blocks or they all have the same timestamp.
+func (bc *Blockchain) EstimateNetworkHashrate(blocks int) (float64, error) {
+	tip, err := bc.GetBlock(bc.Tip)
+	if err != nil {
+		return 0, err
+	}
+
+	// The work of the first block went into the time before its timestamp, so only the later ones count
+	work := new(big.Int)
+	minTime, maxTime := tip.Timestamp, tip.Timestamp
+	for block := tip; blocks > 1 && block.Height > 0; blocks-- {
+		work.Add(work, BlockWork(block.Bits))
+		parent, err := bc.GetBlock(block.PrevHash)
+		if err != nil {
+			return 0, err
+		}
+		block = parent
+		if block.Timestamp < minTime {
+			minTime = block.Timestamp
+		}
+		if block.Timestamp > maxTime {
+			maxTime = block.Timestamp
+		}
+	}
+	if maxTime <= minTime {
+		return 0, nil
+	}
+	hashrate, _ := new(big.Float).Quo(new(big.Float).SetInt(work), big.NewFloat(float64(maxTime-minTime))).Float64()
+	return hashrate, nil
+}
@@ -0,0 +1,100 @@
+package blockchain
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// mineAt mines a block on the tip with the given timestamp, holding only a coinbase tx paying to, and adds it to the chain
+func mineAt(t *testing.T, bc *Blockchain, to string, timestamp int64) {
+	t.Helper()
+	parent, err := bc.GetBlock(bc.Tip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bits, err := bc.GetNextBits(&parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coinbaseTx, err := NewCoinbaseTx(bc.Network, to, "", parent.Height+1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := &Block{timestamp, []*Transaction{coinbaseTx}, parent.Hash, []byte{}, 0, bits, parent.Height + 1}
+	if err = DefaultMiner.Mine(context.Background(), block); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = bc.AddBlock(block); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEstimateNetworkHashrate(t *testing.T) {
+	bc, w := newTestChain(t)
+	address := string(w.GetChromaAddress(bc.Network))
+	genesis, err := bc.GetBlock(bc.Tip)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing to time with only the genesis block, or blocks all mined in the same second
+	if hashrate, err := bc.EstimateNetworkHashrate(10); err != nil || hashrate != 0 {
+		t.Errorf("genesis only: got %v (%v), want 0", hashrate, err)
+	}
+	mineAt(t, bc, address, genesis.Timestamp)
+	if hashrate, err := bc.EstimateNetworkHashrate(10); err != nil || hashrate != 0 {
+		t.Errorf("same timestamps: got %v (%v), want 0", hashrate, err)
+	}
+
+	// Four more blocks a minute apart, so the tip is at 4 minutes past genesis
+	for i := int64(1); i <= 4; i++ {
+		mineAt(t, bc, address, genesis.Timestamp+60*i)
+	}
+	work, _ := new(big.Float).SetInt(BlockWork(genesis.Bits)).Float64()
+	for _, test := range []struct {
+		name   string
+		blocks int
+		want   float64
+	}{
+		{"one block", 1, 0},
+		{"two blocks", 2, work / 60},
+		{"four blocks", 4, 3 * work / 180},
+		// Stops at genesis, and the block in the same second as it adds work but no time
+		{"more blocks than the chain", 100, 5 * work / 240},
+	} {
+		hashrate, err := bc.EstimateNetworkHashrate(test.blocks)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if hashrate != test.want {
+			t.Errorf("%s: got %v hashes/s, want %v", test.name, hashrate, test.want)
+		}
+	}
+}
+
+func TestMiningStats(t *testing.T) {
+	for _, test := range []struct {
+		name             string
+		stats            MiningStats
+		hashesPerSecond  float64
+		attemptsPerBlock float64
+		timeToBlock      time.Duration
+	}{
+		{"nothing mined", MiningStats{}, 0, 0, 0},
+		{"no time spent", MiningStats{Blocks: 2, Hashes: 1000}, 0, 500, 0},
+		{"no blocks found", MiningStats{Hashes: 1000, Duration: 2 * time.Second}, 500, 0, 0},
+		{"blocks found", MiningStats{Blocks: 4, Hashes: 1000, Duration: 2 * time.Second}, 500, 250, 500 * time.Millisecond},
+	} {
+		if got := test.stats.HashesPerSecond(); got != test.hashesPerSecond {
+			t.Errorf("%s: got %v hashes/s, want %v", test.name, got, test.hashesPerSecond)
+		}
+		if got := test.stats.AttemptsPerBlock(); got != test.attemptsPerBlock {
+			t.Errorf("%s: got %v attempts per block, want %v", test.name, got, test.attemptsPerBlock)
+		}
+		if got := test.stats.TimeToBlock(); got != test.timeToBlock {
+			t.Errorf("%s: got %v per block, want %v", test.name, got, test.timeToBlock)
+		}
+	}
+}
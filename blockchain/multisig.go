@@ -0,0 +1,118 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"fmt"
+
+	"github.com/casalettoj/chroma/config"
+	conf "github.com/casalettoj/chroma/constants"
+	wallet "github.com/casalettoj/chroma/wallet"
+)
+
+// NewMultiSigAddress returns the script address on the network of outputs spendable with m signatures from the given
+// public keys, along with the redeem script the address is the hash of
+func NewMultiSigAddress(network *config.Network, m int, pubKeys [][]byte) (address string, redeemScript Script, err error) {
+	// The key count is pushed with a single opcode, OP_1 to OP_16
+	if len(pubKeys) == 0 || len(pubKeys) > 16 {
+		return "", nil, fmt.Errorf("need between 1 and 16 public keys, got %d", len(pubKeys))
+	}
+	if m < 1 || m > len(pubKeys) {
+		return "", nil, fmt.Errorf("can't require %d of %d signatures", m, len(pubKeys))
+	}
+	for i, pubKey := range pubKeys {
+		if _, _, err := wallet.ParsePublicKey(pubKey); err != nil {
+			return "", nil, fmt.Errorf("public key %d (%x) isn't a valid key: %v", i, pubKey, err)
+		}
+	}
+	redeemScript = NewMultiSigScript(m, pubKeys)
+	// Spending pushes the redeem script, so it has to fit in a single stack item
+	if len(redeemScript) > conf.SCRIPTmaxelementsize {
+		return "", nil, fmt.Errorf("redeem script is %d bytes, more than %d", len(redeemScript), conf.SCRIPTmaxelementsize)
+	}
+	return string(wallet.GetScriptAddress(network, redeemScript)), redeemScript, nil
+}
+
+// NewMultiSigTransaction returns an unsigned transaction paying amount from the multisig address of redeemScript
+// to the recipient and leaving fee for the miner. Each input's unlocking script holds just the redeem script,
+// for signers to add their signatures to with Sign.
+func NewMultiSigTransaction(bc *Blockchain, redeemScript Script, to string, amount, fee int) (*Transaction, error) {
+	if _, _, ok := ParseMultiSigScript(redeemScript); !ok {
+		return nil, fmt.Errorf("%w: redeem script isn't a multisig script", ErrInvalidTransaction)
+	}
+	from := string(wallet.GetScriptAddress(bc.Network, redeemScript))
+	tx, err := newPayment(bc, wallet.HashPublicKey(redeemScript), from, to, amount, fee)
+	if err != nil {
+		return nil, err
+	}
+	for i := range tx.Vin {
+		tx.Vin[i].ScriptSig = Script{}.AddData(redeemScript)
+	}
+	return tx, nil
+}
+
+// MultiSigProgress returns how many signatures the P2SH multisig input at inIndex has and how many it needs,
+// or false if the input's unlocking script doesn't end with a multisig redeem script
+func (tx *Transaction) MultiSigProgress(inIndex int) (signatures, required int, ok bool) {
+	pushes, err := tx.Vin[inIndex].ScriptSig.pushes()
+	if err != nil || len(pushes) == 0 {
+		return 0, 0, false
+	}
+	required, _, ok = ParseMultiSigScript(pushes[len(pushes)-1])
+	return len(pushes) - 1, required, ok
+}
+
+// signMultiSig adds a signature of hashType made with privateKey to the unlocking script of the input at inIndex,
+// which spends prevOut, a P2SH output whose multisig redeem script ends the unlocking script.
+// Signatures are kept in the order of their public keys in the redeem script, as OP_CHECKMULTISIG needs.
+// Returns false if pubKey isn't one of the script's keys, has already signed or the input has all the signatures
+// it needs, and an error if hashType can't sign it.
+func (tx *Transaction) signMultiSig(inIndex int, privateKey ecdsa.PrivateKey, pubKey []byte, prevOut TxOutput, hashType SigHashType) (bool, error) {
+	pushes, err := tx.Vin[inIndex].ScriptSig.pushes()
+	if err != nil || len(pushes) == 0 {
+		return false, nil
+	}
+	redeemScript := Script(pushes[len(pushes)-1])
+	if bytes.Compare(wallet.HashPublicKey(redeemScript), prevOut.ScriptPubKey.ScriptHash()) != 0 {
+		return false, nil
+	}
+	m, pubKeys, ok := ParseMultiSigScript(redeemScript)
+	if !ok || len(pushes)-1 >= m {
+		return false, nil
+	}
+
+	// Work out which key made each signature so far
+	engine := scriptEngine{tx: tx, inIndex: inIndex, prevScript: redeemScript}
+	signatures := make([][]byte, len(pubKeys))
+	for _, signature := range pushes[:len(pushes)-1] {
+		for i, key := range pubKeys {
+			if valid, _ := engine.checkSignature(signature, key); signatures[i] == nil && valid {
+				signatures[i] = signature
+				break
+			}
+		}
+	}
+	keyIndex := -1
+	for i, key := range pubKeys {
+		if bytes.Compare(key, pubKey) == 0 {
+			keyIndex = i
+		}
+	}
+	if keyIndex == -1 || signatures[keyIndex] != nil {
+		return false, nil
+	}
+
+	signatures[keyIndex], err = tx.signInput(inIndex, redeemScript, privateKey, hashType)
+	if err != nil {
+		return false, err
+	}
+
+	scriptSig := Script{}
+	for _, signature := range signatures {
+		if signature != nil {
+			scriptSig = scriptSig.AddData(signature)
+		}
+	}
+	tx.Vin[inIndex].ScriptSig = scriptSig.AddData(redeemScript)
+	return true, nil
+}
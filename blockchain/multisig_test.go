@@ -0,0 +1,96 @@
+package blockchain
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	wallet "github.com/casalettoj/chroma/wallet"
+)
+
+// testMultiSig returns three keys and the 2 of 3 multisig redeem script of their public keys
+func testMultiSig() (keys []ecdsa.PrivateKey, pubKeys [][]byte, redeemScript Script) {
+	for _, d := range []int64{0x1111, 0x2222, 0x3333} {
+		key := testKey(elliptic.P256(), big.NewInt(d))
+		keys = append(keys, key)
+		pubKeys = append(pubKeys, wallet.MarshalPublicKey(wallet.P256, key.X, key.Y))
+	}
+	return keys, pubKeys, NewMultiSigScript(2, pubKeys)
+}
+
+func TestCheckMultiSig(t *testing.T) {
+	keys, _, redeemScript := testMultiSig()
+	prevOut := TxOutput{Value: 1, ScriptPubKey: NewP2SHScript(wallet.HashPublicKey(redeemScript))}
+	tx := Transaction{Vin: []TxInput{{TxID: []byte("prev"), Vout: 0}}}
+	signatures := make([][]byte, len(keys))
+	for i, key := range keys {
+		signature, err := tx.signInput(0, redeemScript, key, SigHashAll)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signatures[i] = signature
+	}
+
+	for _, test := range []struct {
+		name string
+		// signers are the indexes of the keys whose signatures go in the unlocking script, in that order
+		signers []int
+		valid   bool
+	}{
+		{"first and second keys", []int{0, 1}, true},
+		{"first and third keys", []int{0, 2}, true},
+		{"second and third keys", []int{1, 2}, true},
+		{"out of key order", []int{1, 0}, false},
+		{"last key first", []int{2, 0}, false},
+		{"duplicate signature", []int{0, 0}, false},
+		{"duplicate last signature", []int{2, 2}, false},
+		{"one signature short", []int{0}, false},
+		{"no signatures", nil, false},
+	} {
+		scriptSig := Script{}
+		for _, signer := range test.signers {
+			scriptSig = scriptSig.AddData(signatures[signer])
+		}
+		tx.Vin[0].ScriptSig = scriptSig.AddData(redeemScript)
+		if err := tx.VerifyInput(0, prevOut); (err == nil) != test.valid {
+			t.Errorf("%s: got %v, want valid %v", test.name, err, test.valid)
+		}
+	}
+}
+
+func TestSignMultiSigKeyOrder(t *testing.T) {
+	keys, pubKeys, redeemScript := testMultiSig()
+	prevTx := Transaction{
+		Vin:  []TxInput{{Vout: -1, ScriptSig: NewDataScript([]byte("multisig"))}},
+		Vout: []TxOutput{{Value: 50, ScriptPubKey: NewP2SHScript(wallet.HashPublicKey(redeemScript))}},
+	}
+	prevTx.ID = prevTx.Hash()
+	prevTxs := map[string]Transaction{hex.EncodeToString(prevTx.ID): prevTx}
+	tx := Transaction{
+		Vin:  []TxInput{{TxID: prevTx.ID, Vout: 0, ScriptSig: Script{}.AddData(redeemScript)}},
+		Vout: []TxOutput{{Value: 49, ScriptPubKey: NewP2PKHScript(wallet.HashPublicKey(pubKeys[0]))}},
+	}
+	tx.ID = tx.Hash()
+
+	// Signing with the last key first still leaves the signatures in key order
+	for _, i := range []int{2, 0} {
+		if signed, err := tx.Sign(keys[i], pubKeys[i], prevTxs, SigHashAll); err != nil || signed != 1 {
+			t.Fatalf("key %d signed %d inputs: %v", i, signed, err)
+		}
+	}
+	if signatures, required, ok := tx.MultiSigProgress(0); !ok || signatures != 2 || required != 2 {
+		t.Errorf("got %d of %d signatures (%v), want 2 of 2", signatures, required, ok)
+	}
+	if !tx.Verify(prevTxs) {
+		t.Error("tx signed out of key order doesn't verify")
+	}
+
+	// A key that already signed, or one more signature once there are enough, adds nothing
+	for _, i := range []int{0, 1} {
+		if signed, err := tx.Sign(keys[i], pubKeys[i], prevTxs, SigHashAll); err != nil || signed != 0 {
+			t.Errorf("key %d signed %d inputs of a fully signed tx: %v", i, signed, err)
+		}
+	}
+}
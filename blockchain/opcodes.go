@@ -0,0 +1,91 @@
+package blockchain
+
+// Opcodes understood by the script interpreter. Their values match Bitcoin's so scripts read the same.
+const (
+	// Op0 pushes an empty byte array (false)
+	Op0 byte = 0x00
+	// OpPushData1 pushes the next N bytes, where N is given by the following byte
+	OpPushData1 byte = 0x4c
+	// OpPushData2 pushes the next N bytes, where N is given by the following two little endian bytes
+	OpPushData2 byte = 0x4d
+	// Op1Negate pushes the number -1
+	Op1Negate byte = 0x4f
+	// Op1 pushes the number 1 (true); Op2 to Op16 follow it
+	Op1 byte = 0x51
+	// Op16 pushes the number 16
+	Op16 byte = 0x60
+
+	// OpNop does nothing
+	OpNop byte = 0x61
+	// OpIf runs the following ops up to OpElse or OpEndIf if the top item is true
+	OpIf byte = 0x63
+	// OpNotIf runs the following ops up to OpElse or OpEndIf if the top item is false
+	OpNotIf byte = 0x64
+	// OpElse runs the following ops if the ops since the matching OpIf or OpNotIf didn't run
+	OpElse byte = 0x67
+	// OpEndIf ends an OpIf or OpNotIf block
+	OpEndIf byte = 0x68
+	// OpVerify fails the script unless the top item is true, removing it
+	OpVerify byte = 0x69
+	// OpReturn fails the script, marking an output as unspendable (e.g. one only carrying data)
+	OpReturn byte = 0x6a
+
+	// OpDrop removes the top item
+	OpDrop byte = 0x75
+	// OpDup duplicates the top item
+	OpDup byte = 0x76
+	// OpSwap swaps the top two items
+	OpSwap byte = 0x7c
+	// OpSize pushes the length of the top item without removing it
+	OpSize byte = 0x82
+
+	// OpEqual replaces the top two items with whether they are byte for byte equal
+	OpEqual byte = 0x87
+	// OpEqualVerify is OpEqual followed by OpVerify
+	OpEqualVerify byte = 0x88
+
+	// OpSha256 replaces the top item with its SHA256 hash
+	OpSha256 byte = 0xa8
+	// OpHash160 replaces the top item with its RIPEMD160(SHA256) hash, the hash addresses are made of
+	OpHash160 byte = 0xa9
+	// OpCheckSig replaces a public key and signature with whether the signature is valid for the tx
+	OpCheckSig byte = 0xac
+	// OpCheckSigVerify is OpCheckSig followed by OpVerify
+	OpCheckSigVerify byte = 0xad
+	// OpCheckMultiSig replaces N public keys, N, M signatures and M with whether every signature is valid
+	// for one of the public keys, in the same order
+	OpCheckMultiSig byte = 0xae
+	// OpCheckMultiSigVerify is OpCheckMultiSig followed by OpVerify
+	OpCheckMultiSigVerify byte = 0xaf
+
+	// OpCheckLockTimeVerify fails the script unless the tx's lock time is at least the height on top of the stack
+	OpCheckLockTimeVerify byte = 0xb1
+)
+
+// opcodeNames are the names scripts are disassembled with
+var opcodeNames = map[byte]string{
+	Op0:                   "OP_0",
+	OpPushData1:           "OP_PUSHDATA1",
+	OpPushData2:           "OP_PUSHDATA2",
+	Op1Negate:             "OP_1NEGATE",
+	OpNop:                 "OP_NOP",
+	OpIf:                  "OP_IF",
+	OpNotIf:               "OP_NOTIF",
+	OpElse:                "OP_ELSE",
+	OpEndIf:               "OP_ENDIF",
+	OpVerify:              "OP_VERIFY",
+	OpReturn:              "OP_RETURN",
+	OpDrop:                "OP_DROP",
+	OpDup:                 "OP_DUP",
+	OpSwap:                "OP_SWAP",
+	OpSize:                "OP_SIZE",
+	OpEqual:               "OP_EQUAL",
+	OpEqualVerify:         "OP_EQUALVERIFY",
+	OpSha256:              "OP_SHA256",
+	OpHash160:             "OP_HASH160",
+	OpCheckSig:            "OP_CHECKSIG",
+	OpCheckSigVerify:      "OP_CHECKSIGVERIFY",
+	OpCheckMultiSig:       "OP_CHECKMULTISIG",
+	OpCheckMultiSigVerify: "OP_CHECKMULTISIGVERIFY",
+	OpCheckLockTimeVerify: "OP_CHECKLOCKTIMEVERIFY",
+}
@@ -3,72 +3,52 @@ package blockchain
 import (
 	"bytes"
 	"crypto/sha256"
-	"fmt"
-	"math"
 	"math/big"
 
 	util "github.com/casalettoj/chroma/utils"
 )
 
-const maxNonce = math.MaxInt64
-const targetBits = 18
-
 // ProofOfWork is a struture containing difficulty target and block being mined.
 type ProofOfWork struct {
 	block  *Block
 	target *big.Int
 }
 
-// NewProofOfWork does the obvious
+// NewProofOfWork does the obvious, using the target stored in the block's bits
 func NewProofOfWork(b *Block) *ProofOfWork {
-	target := big.NewInt(1)
-	target = target.Lsh(target, uint(256-targetBits))
-	pow := &ProofOfWork{b, target}
+	pow := &ProofOfWork{b, CompactToTarget(b.Bits)}
 	return pow
 }
 
 // PrepareData returns a []byte of all headers, the target bits, and nonce.
 func (pow *ProofOfWork) PrepareData(nonce int) []byte {
-	data := bytes.Join([][]byte{
+	return append(pow.prepareHeader(), util.Int64ToByteArray(int64(nonce))...)
+}
+
+// prepareHeader returns the data PrepareData hashes up to the nonce, which stays the same while a Miner tries nonces
+func (pow *ProofOfWork) prepareHeader() []byte {
+	return bytes.Join([][]byte{
 		pow.block.PrevHash,
 		pow.block.HashTransactions(),
 		util.Int64ToByteArray(pow.block.Timestamp),
-		util.Int64ToByteArray(int64(targetBits)),
-		util.Int64ToByteArray(int64(nonce)),
+		util.Int64ToByteArray(int64(pow.block.Bits)),
+		util.Int64ToByteArray(int64(pow.block.Height)),
 	}, []byte{})
-	return data
 }
 
-// Run runs the proof of work algorithm until mined
-func (pow *ProofOfWork) Run() (int, []byte) {
+// IsValid returns whether the PoW is valid and matches the hash recorded in the block.
+func (pow *ProofOfWork) IsValid() bool {
 	var hashInt big.Int
-	var hash [32]byte
-	nonce := 0
 
-	for nonce < maxNonce {
-		preparedData := pow.PrepareData(nonce)
-		hash = sha256.Sum256(preparedData)
-		fmt.Printf("\r%x", hash)
-		hashInt.SetBytes(hash[:])
-		if hashInt.Cmp(pow.target) == -1 {
-			break
-		} else {
-			nonce++
-		}
+	if pow.target.Sign() <= 0 {
+		return false
 	}
-	fmt.Println()
-	return nonce, hash[:]
-}
-
-// IsValid returns whether the PoW is valid.
-func (pow *ProofOfWork) IsValid() bool {
-	var hashInt big.Int
 
 	data := pow.PrepareData(pow.block.Nonce)
 	hash := sha256.Sum256(data)
 	hashInt.SetBytes(hash[:])
 
-	isValid := hashInt.Cmp(pow.target) == -1
+	isValid := hashInt.Cmp(pow.target) == -1 && bytes.Compare(hash[:], pow.block.Hash) == 0
 
 	return isValid
 }
@@ -0,0 +1,259 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	conf "github.com/casalettoj/chroma/constants"
+	util "github.com/casalettoj/chroma/utils"
+	bolt "github.com/coreos/bbolt"
+)
+
+// AddBlock stores a block mined elsewhere (e.g. received from a peer) whose parent is already stored.
+// Blocks that don't build on the tip are kept on a side branch, and once a branch carries more cumulative work
+// than the main chain the chain is reorganized onto it. Returns the blocks that left and joined the main chain,
+// oldest first; both are empty if the tip didn't move. A block that breaks the consensus rules is rejected with an
+// error wrapping ErrInvalidBlock, and one whose parent isn't stored with one wrapping ErrBlockNotFound.
+func (bc *Blockchain) AddBlock(block *Block) (disconnected, connected []*Block, err error) {
+	stored, err := bc.HasBlock(block.Hash)
+	if err != nil || stored {
+		return nil, nil, err
+	}
+	parent, err := bc.GetBlock(block.PrevHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = bc.checkHeader(block, &parent); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidBlock, err)
+	}
+
+	err = bc.DB.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket([]byte(conf.DBblocksbucket)).Put(block.Hash, block.Serialize()); err != nil {
+			return err
+		}
+		return putChainWork(tx, block)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	blockWork, err := bc.GetChainWork(block.Hash)
+	if err != nil {
+		return nil, nil, err
+	}
+	tipWork, err := bc.GetChainWork(bc.Tip)
+	if err != nil {
+		return nil, nil, err
+	}
+	if blockWork.Cmp(tipWork) <= 0 {
+		return nil, nil, nil
+	}
+	return bc.reorganize(block)
+}
+
+// errInvalidBranch rolls back the update of a reorganization onto a branch holding an invalid block
+var errInvalidBranch = errors.New("branch holds an invalid block")
+
+// reorganize moves the main chain onto the branch ending at newTip: blocks back to the fork point are
+// disconnected, then the branch's blocks are checked and connected, all in one update so a crash can't leave the
+// chain between branches. If one of them turns out to be invalid the update is rolled back, leaving the old main
+// chain in place, and it and all of its stored descendants are deleted before an error wrapping ErrInvalidBlock is returned.
+func (bc *Blockchain) reorganize(newTip *Block) (disconnected, connected []*Block, err error) {
+	fork, err := bc.findFork(bc.Tip, newTip.Hash)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for hash := bc.Tip; bytes.Compare(hash, fork) != 0; {
+		block, err := bc.GetBlock(hash)
+		if err != nil {
+			return nil, nil, err
+		}
+		disconnected = append([]*Block{&block}, disconnected...)
+		hash = block.PrevHash
+	}
+	var branch []*Block
+	for block := newTip; bytes.Compare(block.Hash, fork) != 0; {
+		branch = append([]*Block{block}, branch...)
+		parent, err := bc.GetBlock(block.PrevHash)
+		if err != nil {
+			return nil, nil, err
+		}
+		block = &parent
+	}
+
+	invalidAt := -1
+	var invalid error
+	err = bc.DB.Update(func(tx *bolt.Tx) error {
+		for i := len(disconnected) - 1; i >= 0; i-- {
+			if err := disconnectTip(tx, disconnected[i]); err != nil {
+				return err
+			}
+		}
+		for i, block := range branch {
+			if invalid = checkTransactions(bc.Network, block, newTxUTXOView(tx)); invalid != nil {
+				invalidAt = i
+				return errInvalidBranch
+			}
+			if err := connectTip(tx, block); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == errInvalidBranch {
+		if err = bc.removeDescendants(branch[invalidAt].Hash); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, fmt.Errorf("%w: block %x %v", ErrInvalidBlock, branch[invalidAt].Hash, invalid)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	bc.Tip = newTip.Hash
+	return disconnected, branch, nil
+}
+
+// findFork returns the hash of the last block two branches have in common.
+// Cumulative work only grows along a branch, so stepping back whichever side has more work meets at the fork.
+func (bc *Blockchain) findFork(a, b []byte) ([]byte, error) {
+	for bytes.Compare(a, b) != 0 {
+		workA, err := bc.GetChainWork(a)
+		if err != nil {
+			return nil, err
+		}
+		workB, err := bc.GetChainWork(b)
+		if err != nil {
+			return nil, err
+		}
+		if workA.Cmp(workB) >= 0 {
+			block, err := bc.GetBlock(a)
+			if err != nil {
+				return nil, err
+			}
+			a = block.PrevHash
+		}
+		if workB.Cmp(workA) >= 0 {
+			block, err := bc.GetBlock(b)
+			if err != nil {
+				return nil, err
+			}
+			b = block.PrevHash
+		}
+	}
+	return a, nil
+}
+
+// connectTip applies a block on top of the tip to the UTXO set, the height and tx indexes and makes it lasthash
+func connectTip(tx *bolt.Tx, block *Block) error {
+	if err := connectUTXOs(tx, block); err != nil {
+		return err
+	}
+	if err := indexTransactions(tx, block); err != nil {
+		return err
+	}
+	if err := tx.Bucket([]byte(conf.DBheightsbucket)).Put(util.Int64ToByteArray(int64(block.Height)), block.Hash); err != nil {
+		return err
+	}
+	return tx.Bucket([]byte(conf.DBblocksbucket)).Put([]byte(conf.DBlasthash), block.Hash)
+}
+
+// disconnectTip rolls the tip block out of the UTXO set and the height and tx indexes and makes its parent lasthash
+func disconnectTip(tx *bolt.Tx, block *Block) error {
+	if err := disconnectUTXOs(tx, block); err != nil {
+		return err
+	}
+	if err := unindexTransactions(tx, block); err != nil {
+		return err
+	}
+	if err := tx.Bucket([]byte(conf.DBheightsbucket)).Delete(util.Int64ToByteArray(int64(block.Height))); err != nil {
+		return err
+	}
+	return tx.Bucket([]byte(conf.DBblocksbucket)).Put([]byte(conf.DBlasthash), block.PrevHash)
+}
+
+// removeDescendants deletes a block that failed validation along with every stored block building on it, on any
+// branch, so none of them are considered again or left without an ancestor
+func (bc *Blockchain) removeDescendants(hash []byte) error {
+	return bc.DB.Update(func(tx *bolt.Tx) error {
+		children, err := childHashes(tx)
+		if err != nil {
+			return err
+		}
+		for queue := [][]byte{hash}; len(queue) > 0; queue = queue[1:] {
+			for _, name := range []string{conf.DBblocksbucket, conf.DBworkbucket, conf.DBundobucket} {
+				if err := tx.Bucket([]byte(name)).Delete(queue[0]); err != nil {
+					return err
+				}
+			}
+			queue = append(queue, children[string(queue[0])]...)
+		}
+		return nil
+	})
+}
+
+// childHashes maps the hash of every stored block to the hashes of the stored blocks building on it.
+// The genesis block is the child of the empty hash.
+func childHashes(tx *bolt.Tx) (map[string][][]byte, error) {
+	children := make(map[string][][]byte)
+	err := tx.Bucket([]byte(conf.DBblocksbucket)).ForEach(func(k, v []byte) error {
+		if string(k) == conf.DBlasthash {
+			return nil
+		}
+		block, err := DeserializeBlock(v)
+		if err != nil {
+			return err
+		}
+		children[string(block.PrevHash)] = append(children[string(block.PrevHash)], block.Hash)
+		return nil
+	})
+	return children, err
+}
+
+// Reindex rebuilds everything derived from the main chain (the UTXO set, undo data, height and tx indexes)
+// by replaying its blocks from genesis, and the chain work of every stored block, side branches included.
+// Databases created before the tx index existed are reindexed when opened.
+func (bc *Blockchain) Reindex() error {
+	hashes, err := bc.GetBlockHashes()
+	if err != nil {
+		return err
+	}
+	return bc.DB.Update(func(tx *bolt.Tx) error {
+		for _, name := range []string{conf.DButxobucket, conf.DBworkbucket, conf.DBundobucket, conf.DBheightsbucket, conf.DBtxbucket} {
+			if err := tx.DeleteBucket([]byte(name)); err != nil && err != bolt.ErrBucketNotFound {
+				return err
+			}
+			if _, err := tx.CreateBucket([]byte(name)); err != nil {
+				return err
+			}
+		}
+
+		blocksBucket := tx.Bucket([]byte(conf.DBblocksbucket))
+		// Blocks are stored by hash, so walk down from the genesis block to put each one's work after its parent's
+		children, err := childHashes(tx)
+		if err != nil {
+			return err
+		}
+		for queue := children[""]; len(queue) > 0; queue = queue[1:] {
+			block, err := DeserializeBlock(blocksBucket.Get(queue[0]))
+			if err != nil {
+				return err
+			}
+			if err = putChainWork(tx, block); err != nil {
+				return err
+			}
+			queue = append(queue, children[string(block.Hash)]...)
+		}
+
+		for i := len(hashes) - 1; i >= 0; i-- {
+			block, err := DeserializeBlock(blocksBucket.Get(hashes[i]))
+			if err != nil {
+				return err
+			}
+			if err = connectTip(tx, block); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
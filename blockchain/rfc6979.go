@@ -0,0 +1,97 @@
+package blockchain
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha256"
+	"math/big"
+)
+
+// signDeterministic makes an ECDSA signature over hash with a nonce derived from the private key and hash (RFC 6979),
+// so signing the same hash with the same key always gives the same signature
+func signDeterministic(privateKey ecdsa.PrivateKey, hash []byte) (r, s *big.Int) {
+	params := privateKey.Curve.Params()
+	n := params.N
+	e := bitsToInt(hash, n.BitLen())
+	for nonces := newNonceGenerator(privateKey.D, hash, n); ; {
+		k := nonces.next()
+		x, _ := privateKey.Curve.ScalarBaseMult(intToOctets(k, n))
+		r = new(big.Int).Mod(x, n)
+		if r.Sign() == 0 {
+			continue
+		}
+		// s = k⁻¹(e + r·d) mod n
+		s = new(big.Int).Mul(r, privateKey.D)
+		s.Add(s, e)
+		s.Mul(s, new(big.Int).ModInverse(k, n))
+		s.Mod(s, n)
+		if s.Sign() != 0 {
+			return r, s
+		}
+	}
+}
+
+// nonceGenerator is the HMAC-SHA256 DRBG of RFC 6979 section 3.2, giving the candidate nonces for a key and hash
+type nonceGenerator struct {
+	n    *big.Int
+	k, v []byte
+}
+
+// newNonceGenerator seeds the nonce generator with the private key d and hash, for a curve of order n (steps b to g)
+func newNonceGenerator(d *big.Int, hash []byte, n *big.Int) *nonceGenerator {
+	h1 := new(big.Int).Mod(bitsToInt(hash, n.BitLen()), n)
+	seed := append(intToOctets(d, n), intToOctets(h1, n)...)
+
+	g := &nonceGenerator{n: n, k: make([]byte, sha256.Size), v: make([]byte, sha256.Size)}
+	for i := range g.v {
+		g.v[i] = 0x01
+	}
+	g.k = g.mac(g.v, []byte{0x00}, seed)
+	g.v = g.mac(g.v)
+	g.k = g.mac(g.v, []byte{0x01}, seed)
+	g.v = g.mac(g.v)
+	return g
+}
+
+// next returns the next candidate nonce in [1, n-1] (step h); each call after the first moves past the last one returned
+func (g *nonceGenerator) next() *big.Int {
+	for {
+		var t []byte
+		for len(t)*8 < g.n.BitLen() {
+			g.v = g.mac(g.v)
+			t = append(t, g.v...)
+		}
+		k := bitsToInt(t, g.n.BitLen())
+		// Reseed before returning so the next call gives a new candidate, in case this one makes r or s zero
+		g.k = g.mac(g.v, []byte{0x00})
+		g.v = g.mac(g.v)
+		if k.Sign() > 0 && k.Cmp(g.n) < 0 {
+			return k
+		}
+	}
+}
+
+// mac returns the HMAC-SHA256 of the concatenated data under the generator's current K
+func (g *nonceGenerator) mac(data ...[]byte) []byte {
+	mac := hmac.New(sha256.New, g.k)
+	for _, d := range data {
+		mac.Write(d)
+	}
+	return mac.Sum(nil)
+}
+
+// bitsToInt takes the leftmost qlen bits of b as a number (RFC 6979 bits2int)
+func bitsToInt(b []byte, qlen int) *big.Int {
+	i := new(big.Int).SetBytes(b)
+	if excess := len(b)*8 - qlen; excess > 0 {
+		i.Rsh(i, uint(excess))
+	}
+	return i
+}
+
+// intToOctets encodes i as a big endian number as long as the curve order n (RFC 6979 int2octets)
+func intToOctets(i, n *big.Int) []byte {
+	b := i.Bytes()
+	size := (n.BitLen() + 7) / 8
+	return append(make([]byte, size-len(b)), b...)
+}
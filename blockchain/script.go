@@ -0,0 +1,287 @@
+package blockchain
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	conf "github.com/casalettoj/chroma/constants"
+)
+
+// Script is a program for the script interpreter: a series of opcodes, some followed by data to push.
+// Outputs are locked with one (ScriptPubKey) and inputs unlock them with another (ScriptSig).
+type Script []byte
+
+// scriptOp is a single parsed opcode along with the data it pushes, if any
+type scriptOp struct {
+	Op   byte
+	Data []byte
+}
+
+// AddOp returns the script with an opcode appended
+func (s Script) AddOp(op byte) Script {
+	return append(s, op)
+}
+
+// AddData returns the script with an op pushing data appended, using the shortest push that fits
+func (s Script) AddData(data []byte) Script {
+	switch {
+	case len(data) == 0:
+		return append(s, Op0)
+	case len(data) < int(OpPushData1):
+		s = append(s, byte(len(data)))
+	case len(data) <= 0xff:
+		s = append(s, OpPushData1, byte(len(data)))
+	default:
+		length := make([]byte, 2)
+		binary.LittleEndian.PutUint16(length, uint16(len(data)))
+		s = append(append(s, OpPushData2), length...)
+	}
+	return append(s, data...)
+}
+
+// AddInt returns the script with an op pushing a number appended
+func (s Script) AddInt(n int64) Script {
+	switch {
+	case n == 0:
+		return append(s, Op0)
+	case n == -1:
+		return append(s, Op1Negate)
+	case n >= 1 && n <= 16:
+		return append(s, Op1+byte(n-1))
+	}
+	return s.AddData(encodeScriptNum(n))
+}
+
+// NewP2PKHScript returns the standard script locking an output to a public key hash (i.e. an address):
+// the spender has to provide a public key hashing to it and a signature made with that key.
+func NewP2PKHScript(pubKeyHash []byte) Script {
+	return Script{}.AddOp(OpDup).AddOp(OpHash160).AddData(pubKeyHash).AddOp(OpEqualVerify).AddOp(OpCheckSig)
+}
+
+// NewP2PKHScriptSig returns the script unlocking a P2PKH output
+func NewP2PKHScriptSig(signature, pubKey []byte) Script {
+	return Script{}.AddData(signature).AddData(pubKey)
+}
+
+// NewP2SHScript returns the script locking an output to the hash of a redeem script (i.e. a script address):
+// the spender has to provide the redeem script, which is then run against the rest of the unlocking script.
+func NewP2SHScript(scriptHash []byte) Script {
+	return Script{}.AddOp(OpHash160).AddData(scriptHash).AddOp(OpEqual)
+}
+
+// NewMultiSigScript returns a script locking an output to m signatures from the given public keys
+func NewMultiSigScript(m int, pubKeys [][]byte) Script {
+	script := Script{}.AddInt(int64(m))
+	for _, pubKey := range pubKeys {
+		script = script.AddData(pubKey)
+	}
+	return script.AddInt(int64(len(pubKeys))).AddOp(OpCheckMultiSig)
+}
+
+// NewDataScript returns a script that carries data and can never be spent
+func NewDataScript(data []byte) Script {
+	return Script{}.AddOp(OpReturn).AddData(data)
+}
+
+// parse splits the script into its ops
+func (s Script) parse() ([]scriptOp, error) {
+	var ops []scriptOp
+	for i := 0; i < len(s); {
+		op := s[i]
+		i++
+		length := 0
+		switch {
+		case op > Op0 && op < OpPushData1:
+			length = int(op)
+		case op == OpPushData1:
+			if i+1 > len(s) {
+				return nil, errors.New("OP_PUSHDATA1 is missing its length")
+			}
+			length = int(s[i])
+			i++
+		case op == OpPushData2:
+			if i+2 > len(s) {
+				return nil, errors.New("OP_PUSHDATA2 is missing its length")
+			}
+			length = int(binary.LittleEndian.Uint16(s[i:]))
+			i += 2
+		}
+		if i+length > len(s) {
+			return nil, fmt.Errorf("push of %d bytes runs past the end of the script", length)
+		}
+		var data []byte
+		if op < Op1Negate {
+			data = s[i : i+length]
+		}
+		ops = append(ops, scriptOp{op, data})
+		i += length
+	}
+	return ops, nil
+}
+
+// IsPushOnly returns whether the script only pushes data, as unlocking scripts have to
+func (s Script) IsPushOnly() bool {
+	ops, err := s.parse()
+	if err != nil {
+		return false
+	}
+	for _, op := range ops {
+		if op.Op > Op16 {
+			return false
+		}
+	}
+	return true
+}
+
+// IsUnspendable returns whether an output locked with the script can never be spent, so it needn't be kept as a UTXO
+func (s Script) IsUnspendable() bool {
+	return len(s) > 0 && s[0] == OpReturn || len(s) > conf.SCRIPTmaxsize
+}
+
+// PubKeyHash returns the public key hash a P2PKH script locks to, or nil if the script isn't P2PKH
+func (s Script) PubKeyHash() []byte {
+	ops, err := s.parse()
+	if err != nil || len(ops) != 5 {
+		return nil
+	}
+	if ops[0].Op != OpDup || ops[1].Op != OpHash160 || len(ops[2].Data) != 20 ||
+		ops[3].Op != OpEqualVerify || ops[4].Op != OpCheckSig {
+		return nil
+	}
+	return ops[2].Data
+}
+
+// ScriptHash returns the redeem script hash a P2SH script locks to, or nil if the script isn't P2SH
+func (s Script) ScriptHash() []byte {
+	ops, err := s.parse()
+	if err != nil || len(ops) != 3 {
+		return nil
+	}
+	if ops[0].Op != OpHash160 || len(ops[1].Data) != 20 || ops[2].Op != OpEqual {
+		return nil
+	}
+	return ops[1].Data
+}
+
+// ParseMultiSigScript returns the number of signatures a multisig script requires and the public keys it checks them
+// against, or false if the script isn't a multisig script
+func ParseMultiSigScript(s Script) (m int, pubKeys [][]byte, ok bool) {
+	ops, err := s.parse()
+	if err != nil || len(ops) < 4 || ops[len(ops)-1].Op != OpCheckMultiSig {
+		return 0, nil, false
+	}
+	first, last := ops[0].Op, ops[len(ops)-2].Op
+	if first < Op1 || first > Op16 || last < Op1 || last > Op16 {
+		return 0, nil, false
+	}
+	m, n := int(first-Op1+1), int(last-Op1+1)
+	if n != len(ops)-3 || m > n {
+		return 0, nil, false
+	}
+	for _, op := range ops[1 : len(ops)-2] {
+		if len(op.Data) == 0 {
+			return 0, nil, false
+		}
+		pubKeys = append(pubKeys, op.Data)
+	}
+	return m, pubKeys, true
+}
+
+// pushes returns the data pushed by each op of a push only script
+func (s Script) pushes() ([][]byte, error) {
+	ops, err := s.parse()
+	if err != nil {
+		return nil, err
+	}
+	var data [][]byte
+	for _, op := range ops {
+		if op.Op > Op16 {
+			return nil, errors.New("script does more than push data")
+		}
+		data = append(data, op.Data)
+	}
+	return data, nil
+}
+
+// String disassembles the script, showing pushed data as hex
+func (s Script) String() string {
+	ops, err := s.parse()
+	if err != nil {
+		return fmt.Sprintf("[invalid script %x]", []byte(s))
+	}
+	var words []string
+	for _, op := range ops {
+		switch {
+		case op.Data != nil && len(op.Data) > 0:
+			words = append(words, hex.EncodeToString(op.Data))
+		case op.Op >= Op1 && op.Op <= Op16:
+			words = append(words, fmt.Sprintf("OP_%d", op.Op-Op1+1))
+		case opcodeNames[op.Op] != "":
+			words = append(words, opcodeNames[op.Op])
+		default:
+			words = append(words, fmt.Sprintf("OP_UNKNOWN_%02x", op.Op))
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// encodeScriptNum encodes a number as it is kept on the stack: little endian, as few bytes as possible,
+// with the sign in the top bit of the last byte
+func encodeScriptNum(n int64) []byte {
+	if n == 0 {
+		return []byte{}
+	}
+	negative := n < 0
+	if negative {
+		n = -n
+	}
+	var result []byte
+	for n > 0 {
+		result = append(result, byte(n&0xff))
+		n >>= 8
+	}
+	// If the top bit is taken, add a byte for the sign
+	if result[len(result)-1]&0x80 != 0 {
+		if negative {
+			result = append(result, 0x80)
+		} else {
+			result = append(result, 0x00)
+		}
+	} else if negative {
+		result[len(result)-1] |= 0x80
+	}
+	return result
+}
+
+// decodeScriptNum decodes a number from the stack, which may be at most maxLength bytes
+func decodeScriptNum(data []byte, maxLength int) (int64, error) {
+	if len(data) > maxLength {
+		return 0, fmt.Errorf("number is %d bytes, more than %d", len(data), maxLength)
+	}
+	if len(data) == 0 {
+		return 0, nil
+	}
+	var n int64
+	for i, b := range data {
+		n |= int64(b) << uint(8*i)
+	}
+	// Clear the sign bit and apply it
+	if data[len(data)-1]&0x80 != 0 {
+		n &= ^(int64(0x80) << uint(8*(len(data)-1)))
+		return -n, nil
+	}
+	return n, nil
+}
+
+// asBool returns whether a stack item counts as true: anything but zero or negative zero
+func asBool(data []byte) bool {
+	for i, b := range data {
+		if b != 0 {
+			return !(i == len(data)-1 && b == 0x80)
+		}
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package blockchain
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+)
+
+// SigHashType is the byte appended to a signature, saying which parts of the tx it signs. Values match Bitcoin's.
+type SigHashType byte
+
+const (
+	// SigHashAll signs every input and output
+	SigHashAll SigHashType = 0x01
+	// SigHashNone signs the inputs but no outputs, letting anyone choose where the coins go
+	SigHashNone SigHashType = 0x02
+	// SigHashSingle signs the inputs and only the output at the same index as the signed input
+	SigHashSingle SigHashType = 0x03
+	// SigHashAnyoneCanPay modifies the other types to sign only the signed input, so others can add theirs
+	SigHashAnyoneCanPay SigHashType = 0x80
+)
+
+var sigHashNames = map[SigHashType]string{
+	SigHashAll:    "ALL",
+	SigHashNone:   "NONE",
+	SigHashSingle: "SINGLE",
+}
+
+// base returns the type without the SigHashAnyoneCanPay modifier
+func (t SigHashType) base() SigHashType {
+	return t &^ SigHashAnyoneCanPay
+}
+
+// valid returns whether t is one of the defined types, with or without SigHashAnyoneCanPay
+func (t SigHashType) valid() bool {
+	_, ok := sigHashNames[t.base()]
+	return ok
+}
+
+// String returns the name of the type, such as ALL or SINGLE|ANYONECANPAY
+func (t SigHashType) String() string {
+	if !t.valid() {
+		return fmt.Sprintf("UNKNOWN(%02x)", byte(t))
+	}
+	name := sigHashNames[t.base()]
+	if t&SigHashAnyoneCanPay != 0 {
+		name += "|ANYONECANPAY"
+	}
+	return name
+}
+
+// ParseSigHashType returns the type with a name String returns
+func ParseSigHashType(name string) (SigHashType, error) {
+	parts := strings.Split(strings.ToUpper(name), "|")
+	var t SigHashType
+	for base, baseName := range sigHashNames {
+		if parts[0] == baseName {
+			t = base
+		}
+	}
+	if t == 0 || len(parts) > 2 || (len(parts) == 2 && parts[1] != "ANYONECANPAY") {
+		return 0, fmt.Errorf("unknown sighash type %q, must be ALL, NONE or SINGLE, optionally followed by |ANYONECANPAY", name)
+	}
+	if len(parts) == 2 {
+		t |= SigHashAnyoneCanPay
+	}
+	return t, nil
+}
+
+// SignatureHash returns the hash a signature of hashType for the input at inIndex signs: the tx with every unlocking
+// script removed and the locking script of the output being spent put in its place for that input, then cut down to
+// the inputs and outputs hashType covers, followed by hashType itself.
+// Returns an error if hashType isn't defined, or is SigHashSingle and there's no output at inIndex.
+func (tx *Transaction) SignatureHash(inIndex int, prevScript Script, hashType SigHashType) ([]byte, error) {
+	if !hashType.valid() {
+		return nil, fmt.Errorf("unknown sighash type %02x", byte(hashType))
+	}
+	txCopy := tx.TrimmedCopy()
+	txCopy.ID = []byte{}
+	txCopy.Vin[inIndex].ScriptSig = prevScript
+
+	switch hashType.base() {
+	case SigHashNone:
+		txCopy.Vout = nil
+	case SigHashSingle:
+		if inIndex >= len(txCopy.Vout) {
+			return nil, fmt.Errorf("SIGHASH_SINGLE input %d has no output at its index", inIndex)
+		}
+		// Outputs before the signed one are kept as blanks so it stays at the same index
+		txCopy.Vout = txCopy.Vout[:inIndex+1]
+		for i := 0; i < inIndex; i++ {
+			txCopy.Vout[i] = TxOutput{Value: -1}
+		}
+	}
+	if hashType&SigHashAnyoneCanPay != 0 {
+		txCopy.Vin = txCopy.Vin[inIndex : inIndex+1]
+	}
+
+	hash := sha256.Sum256(append(txCopy.Serialize(), byte(hashType)))
+	return hash[:], nil
+}
+
+// signInput signs the input at inIndex, spending an output locked by prevScript, with privateKey. Returns the signature
+// with hashType appended, as it goes in an unlocking script, or an error if SignatureHash can't make a hash to sign.
+func (tx *Transaction) signInput(inIndex int, prevScript Script, privateKey ecdsa.PrivateKey, hashType SigHashType) ([]byte, error) {
+	hash, err := tx.SignatureHash(inIndex, prevScript, hashType)
+	if err != nil {
+		return nil, err
+	}
+	return append(signHash(privateKey, hash), byte(hashType)), nil
+}
@@ -0,0 +1,113 @@
+package blockchain
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+
+	conf "github.com/casalettoj/chroma/constants"
+	wallet "github.com/casalettoj/chroma/wallet"
+)
+
+// signHash signs hash with privateKey, returning the DER encoded signature with S in the lower half of the curve's order.
+// The nonce is derived deterministically (RFC 6979), so the same key and hash always give the same signature.
+func signHash(privateKey ecdsa.PrivateKey, hash []byte) []byte {
+	r, s := signDeterministic(privateKey, hash)
+	// (r, N-s) is just as valid as (r, s), so only the lower of the two is accepted
+	if !isLowS(s, privateKey.Curve.Params().N) {
+		s.Sub(privateKey.Curve.Params().N, s)
+	}
+	return encodeSignature(r, s)
+}
+
+// verifySignature returns whether signature was made over hash with the private key of pubKey.
+// A signature that isn't strict DER or has a high S is an error rather than just invalid, so nobody but the signer
+// can produce another encoding of a signature that passes.
+func verifySignature(signature, pubKey, hash []byte) (bool, error) {
+	if len(signature) == 0 {
+		return false, nil
+	}
+	r, s, err := parseSignature(signature)
+	if err != nil {
+		return false, err
+	}
+	rawPubKey, _, err := wallet.ParsePublicKey(pubKey)
+	if err != nil {
+		return false, nil
+	}
+	if !isLowS(s, rawPubKey.Curve.Params().N) {
+		return false, errors.New("signature S is in the upper half of the curve's order")
+	}
+	return ecdsa.Verify(rawPubKey, hash, r, s), nil
+}
+
+// isLowS returns whether s is at most half the curve order n
+func isLowS(s, n *big.Int) bool {
+	return s.Cmp(new(big.Int).Rsh(n, 1)) <= 0
+}
+
+// encodeSignature DER encodes a signature: SEQUENCE { INTEGER r, INTEGER s }
+func encodeSignature(r, s *big.Int) []byte {
+	rBytes := derInteger(r)
+	sBytes := derInteger(s)
+	signature := []byte{0x30, byte(4 + len(rBytes) + len(sBytes))}
+	signature = append(signature, 0x02, byte(len(rBytes)))
+	signature = append(signature, rBytes...)
+	signature = append(signature, 0x02, byte(len(sBytes)))
+	return append(signature, sBytes...)
+}
+
+// derInteger returns the shortest big endian encoding of a positive integer, with a leading zero if its top bit is set
+func derInteger(i *big.Int) []byte {
+	b := i.Bytes()
+	if len(b) == 0 || b[0]&0x80 != 0 {
+		b = append([]byte{0x00}, b...)
+	}
+	return b
+}
+
+// parseSignature decodes a DER encoded signature, which has to be in the one strict form encodeSignature gives (BIP-66)
+func parseSignature(signature []byte) (r, s *big.Int, err error) {
+	if len(signature) < 8 || len(signature) > conf.SCRIPTmaxsignaturesize {
+		return nil, nil, fmt.Errorf("signature is %d bytes, not between 8 and %d", len(signature), conf.SCRIPTmaxsignaturesize)
+	}
+	if signature[0] != 0x30 || int(signature[1]) != len(signature)-2 {
+		return nil, nil, errors.New("signature isn't a DER sequence of its length")
+	}
+	rLen := int(signature[3])
+	if 5+rLen >= len(signature) {
+		return nil, nil, errors.New("signature R runs past its end")
+	}
+	sLen := int(signature[5+rLen])
+	if 6+rLen+sLen != len(signature) {
+		return nil, nil, errors.New("signature R and S don't fill its length")
+	}
+	rBytes, err := parseDERInteger(signature[2 : 4+rLen])
+	if err != nil {
+		return nil, nil, fmt.Errorf("signature R: %v", err)
+	}
+	sBytes, err := parseDERInteger(signature[4+rLen:])
+	if err != nil {
+		return nil, nil, fmt.Errorf("signature S: %v", err)
+	}
+	return new(big.Int).SetBytes(rBytes), new(big.Int).SetBytes(sBytes), nil
+}
+
+// parseDERInteger returns the value of a DER INTEGER (tag, length and value) holding a positive number, minimally encoded
+func parseDERInteger(b []byte) ([]byte, error) {
+	value := b[2:]
+	switch {
+	case b[0] != 0x02:
+		return nil, errors.New("not an integer")
+	case len(value) == 0:
+		return nil, errors.New("empty")
+	case value[0]&0x80 != 0:
+		return nil, errors.New("negative")
+	case len(value) > 1 && value[0] == 0x00 && value[1]&0x80 == 0:
+		return nil, errors.New("has a needless leading zero")
+	case new(big.Int).SetBytes(value).Sign() == 0:
+		return nil, errors.New("zero")
+	}
+	return value, nil
+}
@@ -0,0 +1,227 @@
+package blockchain
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	wallet "github.com/casalettoj/chroma/wallet"
+)
+
+// hexInt parses a hex number, panicking on a typo in a test vector
+func hexInt(s string) *big.Int {
+	i, ok := new(big.Int).SetString(s, 16)
+	if !ok {
+		panic("bad hex number " + s)
+	}
+	return i
+}
+
+// testKey returns the key pair with private key d on the curve
+func testKey(curve elliptic.Curve, d *big.Int) ecdsa.PrivateKey {
+	private := ecdsa.PrivateKey{D: d}
+	private.PublicKey.Curve = curve
+	private.PublicKey.X, private.PublicKey.Y = curve.ScalarBaseMult(d.Bytes())
+	return private
+}
+
+func TestSignDeterministic(t *testing.T) {
+	p256Key := hexInt("C9AFA9D845BA75166B5C215767B1D6934E50C3DB36E89B127B8A622B120F6721")
+	tests := []struct {
+		name    string
+		key     ecdsa.PrivateKey
+		message string
+		k, r, s string
+	}{
+		// RFC 6979 A.2.5, with SHA-256
+		{"p256 sample", testKey(elliptic.P256(), p256Key), "sample",
+			"A6E3C57DD01ABE90086538398355DD4C3B17AA873382B0F24D6129493D8AAD60",
+			"EFD48B2AACB6A8FD1140DD9CD45E81D69D2C877B56AAF991C34D0EA84EAF3716",
+			"F7CB1C942D657C41D436C7A1B6E29F65F3E900DBB9AFF4064DC4AB2F843ACDA8"},
+		{"p256 test", testKey(elliptic.P256(), p256Key), "test",
+			"D16B6AE827F17175E040871A1C7EC3500192C4C92677336EC2537ACAEE0008E0",
+			"F1ABB023518351CD71D881567B1EA663ED3EFCF6C5132B354F28D3B0B7D38367",
+			"019F4113742A2B14BD25926B49C649155F267E60D3814B4C0CC84250E46F0083"},
+		// The secp256k1 vector bitcoin implementations share, published with S already in the lower half
+		{"secp256k1", testKey(btcec.S256(), big.NewInt(1)), "Satoshi Nakamoto",
+			"8F8A276C19F4149656B280621E358CCE24F5F52542772691EE69063B74F15D15",
+			"934B1EA10A4B3C1757E2B0C017D0B6143CE3C9A7E6A4A49860D7A6AB210EE3D8",
+			"2442CE9D2B916064108014783E923EC36B49743E2FFA1C4496F01A512AAFD9E5"},
+	}
+	for _, test := range tests {
+		hash := sha256.Sum256([]byte(test.message))
+		n := test.key.Curve.Params().N
+		if k := newNonceGenerator(test.key.D, hash[:], n).next(); k.Cmp(hexInt(test.k)) != 0 {
+			t.Errorf("%s: got k %X, want %s", test.name, k, test.k)
+		}
+		// Vectors published with a low S may have been normalized from the raw one, which is then N-S
+		wantS := hexInt(test.s)
+		r, s := signDeterministic(test.key, hash[:])
+		if r.Cmp(hexInt(test.r)) != 0 || (s.Cmp(wantS) != 0 && !(isLowS(wantS, n) && new(big.Int).Sub(n, s).Cmp(wantS) == 0)) {
+			t.Errorf("%s: got r %X s %X, want r %s s %s", test.name, r, s, test.r, test.s)
+		}
+
+		// signHash gives the same signature, with S moved to the lower half
+		if !isLowS(wantS, n) {
+			wantS.Sub(n, wantS)
+		}
+		r, s, err := parseSignature(signHash(test.key, hash[:]))
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if r.Cmp(hexInt(test.r)) != 0 || s.Cmp(wantS) != 0 {
+			t.Errorf("%s: signHash gave r %X s %X, want r %s s %X", test.name, r, s, test.r, wantS)
+		}
+	}
+}
+
+func TestSignTransactionGolden(t *testing.T) {
+	private := testKey(elliptic.P256(), big.NewInt(0x1234))
+	pubKey := wallet.MarshalPublicKey(wallet.P256, private.X, private.Y)
+	lock := NewP2PKHScript(wallet.HashPublicKey(pubKey))
+
+	prevTx := Transaction{
+		Vin:  []TxInput{{Vout: -1, ScriptSig: NewDataScript([]byte("golden"))}},
+		Vout: []TxOutput{{Value: 50, ScriptPubKey: lock}},
+	}
+	prevTx.ID = prevTx.Hash()
+	tx := Transaction{
+		Vin:  []TxInput{{TxID: prevTx.ID, Vout: 0}},
+		Vout: []TxOutput{{Value: 30, ScriptPubKey: lock}, {Value: 19, ScriptPubKey: lock}},
+	}
+	tx.ID = tx.Hash()
+
+	prevTxs := map[string]Transaction{hex.EncodeToString(prevTx.ID): prevTx}
+	if signed, err := tx.Sign(private, pubKey, prevTxs, SigHashAll); err != nil || signed != 1 {
+		t.Fatalf("signed %d inputs: %v", signed, err)
+	}
+	if !tx.Verify(prevTxs) {
+		t.Error("signed tx doesn't verify")
+	}
+
+	const (
+		wantID = "2940504e9d14e71398fb79c77fa685ba9fc9d91453a28967d5588c1be8d1d8f2"
+		wantTx = "3f7f0301010b5472616e73616374696f6e01ff8000010401024944010a00010356696e01ff84000104566f757401ff880001084c6f636b54696d65010400000023ff83020101145b5d626c6f636b636861696e2e5478496e70757401ff840001ff82000035ff81030101075478496e70757401ff82000103010454784944010a000104566f75740104000109536372697074536967010a00000024ff87020101155b5d626c6f636b636861696e2e54784f757470757401ff880001ff86000031ff850301010854784f757470757401ff86000102010556616c7565010400010c5363726970745075624b6579010a000000fff6ff8001202940504e9d14e71398fb79c77fa685ba9fc9d91453a28967d5588c1be8d1d8f20101012080f76ac80fd50c71e207d0db0fe1a35af8076e2654214eff4b25bbc5f845f9ca026c483045022100b8d536de19c543f897272cafd29eafaf6027cb38dd73a728f6a204ce9667aa45022045e86d7d660302ddd4fc323bb942333c47e97e50360b142238640089349eb9f901220102ed5784a75391dc43adcd42dbc4c938e80690c75b3f4309049d5076692f8dafe9000102013c011976a914e9573f044331e6a3441019ac90941e79e5b4508188ac000126011976a914e9573f044331e6a3441019ac90941e79e5b4508188ac0000"
+	)
+	if id := hex.EncodeToString(tx.ID); id != wantID {
+		t.Errorf("got ID %s, want %s", id, wantID)
+	}
+	if serialized := hex.EncodeToString(tx.Serialize()); serialized != wantTx {
+		t.Errorf("got tx %s, want %s", serialized, wantTx)
+	}
+}
+
+func TestSignErrors(t *testing.T) {
+	private := testKey(elliptic.P256(), big.NewInt(0x1234))
+	pubKey := wallet.MarshalPublicKey(wallet.P256, private.X, private.Y)
+	lock := NewP2PKHScript(wallet.HashPublicKey(pubKey))
+	prevTx := Transaction{
+		Vin:  []TxInput{{Vout: -1, ScriptSig: NewDataScript([]byte("sign errors"))}},
+		Vout: []TxOutput{{Value: 50, ScriptPubKey: lock}, {Value: 50, ScriptPubKey: lock}},
+	}
+	prevTx.ID = prevTx.Hash()
+	prevTxs := map[string]Transaction{hex.EncodeToString(prevTx.ID): prevTx}
+	newTx := func() *Transaction {
+		tx := &Transaction{
+			Vin:  []TxInput{{TxID: prevTx.ID, Vout: 0}, {TxID: prevTx.ID, Vout: 1}},
+			Vout: []TxOutput{{Value: 90, ScriptPubKey: lock}},
+		}
+		tx.ID = tx.Hash()
+		return tx
+	}
+
+	// The second input has no output at its index for SIGHASH_SINGLE to sign, so it's skipped
+	if signed, err := newTx().Sign(private, pubKey, prevTxs, SigHashSingle); err != nil || signed != 1 {
+		t.Errorf("SIGHASH_SINGLE signed %d inputs: %v", signed, err)
+	}
+	if _, err := newTx().Sign(private, pubKey, prevTxs, SigHashType(0x04)); !errors.Is(err, ErrInvalidTransaction) {
+		t.Errorf("signing with an unknown sighash type gave %v, want ErrInvalidTransaction", err)
+	}
+	missing := newTx()
+	missing.Vin[1].Vout = 2
+	if _, err := missing.Sign(private, pubKey, prevTxs, SigHashAll); !errors.Is(err, ErrInvalidTransaction) {
+		t.Errorf("signing a spend of a missing output gave %v, want ErrInvalidTransaction", err)
+	}
+}
+
+// derSignature encodes R and S as given, without normalizing them, to build badly encoded signatures
+func derSignature(r, s []byte) []byte {
+	signature := []byte{0x30, byte(4 + len(r) + len(s)), 0x02, byte(len(r))}
+	signature = append(signature, r...)
+	signature = append(signature, 0x02, byte(len(s)))
+	return append(signature, s...)
+}
+
+func TestVerifySignatureEncoding(t *testing.T) {
+	for _, keyCurve := range []struct {
+		name  string
+		id    wallet.KeyCurve
+		curve elliptic.Curve
+	}{
+		{"p256", wallet.P256, elliptic.P256()},
+		{"secp256k1", wallet.Secp256k1, btcec.S256()},
+	} {
+		name, curve := keyCurve.name, keyCurve.curve
+		key := testKey(curve, big.NewInt(0x1234))
+		pubKey := wallet.MarshalPublicKey(keyCurve.id, key.X, key.Y)
+		hash := sha256.Sum256([]byte("encoding"))
+		signature := signHash(key, hash[:])
+		r, s, err := parseSignature(signature)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		n := curve.Params().N
+
+		if valid, err := verifySignature(signature, pubKey, hash[:]); err != nil || !valid {
+			t.Errorf("%s: canonical signature gave %v, %v", name, valid, err)
+		}
+		otherHash := sha256.Sum256([]byte("other"))
+		if valid, err := verifySignature(signature, pubKey, otherHash[:]); err != nil || valid {
+			t.Errorf("%s: signature of another hash gave %v, %v, want invalid without an error", name, valid, err)
+		}
+
+		rBytes, sBytes := derInteger(r), derInteger(s)
+		highS := derInteger(new(big.Int).Sub(n, s))
+		// Dropping the padding of an integer with its top bit set makes it negative; 0x80 alone is a negative byte too
+		negative := []byte{0x80}
+		if rBytes[0] == 0x00 {
+			negative = rBytes[1:]
+		}
+		longLength := append([]byte{}, signature...)
+		longLength[1]++
+		trailing := append(append([]byte{}, signature...), 0x01)
+		trailingInSequence := append([]byte{}, trailing...)
+		trailingInSequence[1]++
+		notInteger := append([]byte{}, signature...)
+		notInteger[2] = 0x03
+
+		for _, test := range []struct {
+			name      string
+			signature []byte
+		}{
+			{"high S", derSignature(rBytes, highS)},
+			{"R with a needless leading zero", derSignature(append([]byte{0x00}, rBytes...), sBytes)},
+			{"S with a needless leading zero", derSignature(rBytes, append([]byte{0x00}, sBytes...))},
+			{"negative R", derSignature(negative, sBytes)},
+			{"negative S", derSignature(rBytes, []byte{0x80 | sBytes[0]})},
+			{"zero R", derSignature([]byte{0x00}, sBytes)},
+			{"empty S", derSignature(rBytes, nil)},
+			{"sequence longer than the signature", longLength},
+			{"trailing byte", trailing},
+			{"trailing byte inside the sequence", trailingInSequence},
+			{"R that isn't an integer", notInteger},
+			{"too short", signature[:7]},
+			{"too long", derSignature(append(make([]byte, 33), rBytes...), sBytes)},
+		} {
+			if valid, err := verifySignature(test.signature, pubKey, hash[:]); err == nil {
+				t.Errorf("%s: %s %x gave %v without an error", name, test.name, test.signature, valid)
+			}
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package blockchain
+
+import (
+	"github.com/casalettoj/chroma/config"
+)
+
+// GetBlockSubsidy returns the number of new coins the coinbase tx of the block at the given height on the network
+// may create. It halves every HalvingInterval blocks until it reaches zero.
+func GetBlockSubsidy(network *config.Network, height int) int {
+	halvings := uint(height / network.HalvingInterval)
+	if halvings >= 63 {
+		return 0
+	}
+	return network.InitialSubsidy >> halvings
+}
+
+// ScheduledSupply returns the number of coins the network's schedule allows to exist once the block at the given
+// height is mined
+func ScheduledSupply(network *config.Network, height int) int {
+	supply := 0
+	for eraStart := 0; eraStart <= height; eraStart += network.HalvingInterval {
+		subsidy := GetBlockSubsidy(network, eraStart)
+		if subsidy == 0 {
+			break
+		}
+		blocks := network.HalvingInterval
+		if height-eraStart+1 < blocks {
+			blocks = height - eraStart + 1
+		}
+		supply += subsidy * blocks
+	}
+	return supply
+}
+
+// MaxMoney returns the most coins any output, transaction or block may move on the network: no more can ever exist
+func MaxMoney(network *config.Network) int {
+	return MaxSupply(network)
+}
+
+// MaxSupply returns the number of coins that will ever exist on the network once the subsidy has halved down to zero
+func MaxSupply(network *config.Network) int {
+	supply := 0
+	for eraStart := 0; GetBlockSubsidy(network, eraStart) > 0; eraStart += network.HalvingInterval {
+		supply += GetBlockSubsidy(network, eraStart) * network.HalvingInterval
+	}
+	return supply
+}
@@ -0,0 +1,68 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/casalettoj/chroma/config"
+)
+
+// testSchedule is a network whose subsidy starts at 100 and halves every 10 blocks
+var testSchedule = config.Network{Name: "test", InitialSubsidy: 100, HalvingInterval: 10}
+
+func TestGetBlockSubsidy(t *testing.T) {
+	tests := []struct {
+		height, want int
+	}{
+		{0, 100},
+		{9, 100},
+		{10, 50},
+		{19, 50},
+		{20, 25},
+		{60, 1},
+		{69, 1},
+		{70, 0},
+		{630, 0},
+		{1 << 40, 0},
+	}
+	for _, test := range tests {
+		if got := GetBlockSubsidy(&testSchedule, test.height); got != test.want {
+			t.Errorf("subsidy at height %d is %d, want %d", test.height, got, test.want)
+		}
+	}
+}
+
+func TestScheduledSupply(t *testing.T) {
+	tests := []struct {
+		height, want int
+	}{
+		{0, 100},
+		{9, 1000},
+		{10, 1050},
+		{19, 1500},
+		{20, 1525},
+		{69, 1970},
+		{70, 1970},
+		{1000, 1970},
+	}
+	for _, test := range tests {
+		if got := ScheduledSupply(&testSchedule, test.height); got != test.want {
+			t.Errorf("supply at height %d is %d, want %d", test.height, got, test.want)
+		}
+	}
+}
+
+func TestMaxSupply(t *testing.T) {
+	// 100, 50, 25, 12, 6, 3, 1 for 10 blocks each
+	if got := MaxSupply(&testSchedule); got != 1970 {
+		t.Errorf("max supply is %d, want 1970", got)
+	}
+	for _, network := range []*config.Network{&config.Mainnet, &config.Testnet, &config.Regtest} {
+		max := MaxSupply(network)
+		if max <= 0 || max >= 2*network.InitialSubsidy*network.HalvingInterval {
+			t.Errorf("%s: max supply %d isn't below twice the first era's", network.Name, max)
+		}
+		if got := ScheduledSupply(network, 64*network.HalvingInterval); got != max {
+			t.Errorf("%s: supply once the subsidy runs out is %d, want %d", network.Name, got, max)
+		}
+	}
+}
@@ -3,40 +3,53 @@ package blockchain
 import (
 	"bytes"
 	"crypto/ecdsa"
-	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/gob"
 	"encoding/hex"
 	"fmt"
-	"log"
-	"math/big"
+	"io/ioutil"
+	"sort"
 	"strings"
 
-	"github.com/btcsuite/btcutil/base58"
-
-	conf "github.com/casalettoj/chroma/constants"
+	"github.com/casalettoj/chroma/config"
 	util "github.com/casalettoj/chroma/utils"
 	wallet "github.com/casalettoj/chroma/wallet"
 )
 
-// Transaction is a collection of inputs and outputs with its hashed data as an ID
+// Transaction is a collection of inputs and outputs with its hashed data as an ID.
+// A non-zero LockTime is the lowest height of a block the tx can be mined in.
 type Transaction struct {
-	ID   []byte
-	Vin  []TxInput
-	Vout []TxOutput
+	ID       []byte
+	Vin      []TxInput
+	Vout     []TxOutput
+	LockTime int
+}
+
+// init registers the tx types with gob before anything else is encoded or decoded. The gob type IDs they get are
+// part of a serialized tx and so of its hash, and would otherwise depend on what the process happened to handle
+// first, e.g. a reply from a node carrying txs, giving the same tx different IDs in different processes.
+func init() {
+	util.CheckAnxiety(gob.NewEncoder(ioutil.Discard).Encode(Transaction{}))
 }
 
 func (tx *Transaction) String() string {
 	var lines []string
 	lines = append(lines, fmt.Sprintf("___TX %x: (Vin: %d, Vout: %d)___\n", tx.ID, len(tx.Vin), len(tx.Vout)))
+	if tx.LockTime != 0 {
+		lines = append(lines, fmt.Sprintf("Lock time: %d\n", tx.LockTime))
+	}
 	for i, input := range tx.Vin {
-		lines = append(lines, fmt.Sprintf("Input %d:\nTxID: %x\nVout: %d\nSig: %x\nPubKey: %x\n", i, input.TxID, input.Vout, input.Signature, input.PubKey))
+		if tx.IsCoinbaseTx() {
+			lines = append(lines, fmt.Sprintf("Input %d:\nCoinbase: %x\n", i, []byte(input.ScriptSig)))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("Input %d:\nTxID: %x\nVout: %d\nScriptSig: %s\n", i, input.TxID, input.Vout, input.ScriptSig))
 
 	}
 	lines = append(lines, fmt.Sprintln())
 	for i, output := range tx.Vout {
-		lines = append(lines, fmt.Sprintf("Output %d:\nValue: %d\nPubKeyHash: %x\n", i, output.Value, output.PubKeyHash))
+		lines = append(lines, fmt.Sprintf("Output %d:\nValue: %d\nScriptPubKey: %s\n", i, output.Value, output.ScriptPubKey))
 	}
 	lines = append(lines, fmt.Sprintf("___\n\n"))
 	return strings.Join(lines, "")
@@ -56,6 +69,16 @@ func (tx *Transaction) Hash() []byte {
 	return hash[:]
 }
 
+// ComputeID returns the ID the tx should have: the hash of its data with the unlocking scripts left out,
+// as it was before signing. A coinbase tx's is kept, since it isn't signed and makes the tx unique.
+func (tx *Transaction) ComputeID() []byte {
+	if tx.IsCoinbaseTx() {
+		return tx.Hash()
+	}
+	txCopy := tx.TrimmedCopy()
+	return txCopy.Hash()
+}
+
 // Serialize returns a byte slice representation of the tx
 func (tx *Transaction) Serialize() []byte {
 	var buffer bytes.Buffer
@@ -64,122 +87,186 @@ func (tx *Transaction) Serialize() []byte {
 	return buffer.Bytes()
 }
 
-// TrimmedCopy returns a copy of the transaction with inputs stripped of their PubKey and Signature fields.
+// DeserializeTransaction deserializes a byte array into a Transaction struct
+func DeserializeTransaction(data []byte) (Transaction, error) {
+	var tx Transaction
+	decoder := gob.NewDecoder(bytes.NewReader(data))
+	if err := decoder.Decode(&tx); err != nil {
+		return Transaction{}, fmt.Errorf("%w: tx: %v", ErrCorruptData, err)
+	}
+	return tx, nil
+}
+
+// TrimmedCopy returns a copy of the transaction with inputs stripped of their unlocking scripts.
 func (tx *Transaction) TrimmedCopy() Transaction {
 	var vin []TxInput
 	var vout []TxOutput
 
 	for _, in := range tx.Vin {
-		vin = append(vin, TxInput{TxID: in.TxID, Vout: in.Vout, Signature: nil, PubKey: nil})
+		vin = append(vin, TxInput{TxID: in.TxID, Vout: in.Vout, ScriptSig: nil})
 	}
 	for _, out := range tx.Vout {
-		vout = append(vout, TxOutput{Value: out.Value, PubKeyHash: out.PubKeyHash})
+		vout = append(vout, TxOutput{Value: out.Value, ScriptPubKey: out.ScriptPubKey})
 	}
 
-	return Transaction{Vin: vin, Vout: vout, ID: tx.ID}
+	return Transaction{Vin: vin, Vout: vout, ID: tx.ID, LockTime: tx.LockTime}
 }
 
-// Sign signs a transaction with the private key of a wallet and a hash of the transaction
-func (tx *Transaction) Sign(privateKey ecdsa.PrivateKey, prevTxs map[string]Transaction) {
+// Sign signs every input it can with the private key of pubKey, with signatures of hashType: those spending a P2PKH
+// output locked to pubKey get an unlocking script with the signature and pubKey, and multisig inputs pubKey is a signer
+// of get the signature added to theirs. Inputs hashType can't sign, SigHashSingle ones without a matching output,
+// are left unsigned. Returns the number of inputs signed, or an error wrapping ErrInvalidTransaction if prevTxs
+// is missing a transaction an input spends or an input can't be signed.
+func (tx *Transaction) Sign(privateKey ecdsa.PrivateKey, pubKey []byte, prevTxs map[string]Transaction, hashType SigHashType) (signed int, err error) {
 	if tx.IsCoinbaseTx() {
-		return
+		return 0, nil
 	}
-	txCopy := tx.TrimmedCopy()
-	for _, vin := range tx.Vin {
-		if prevTxs[hex.EncodeToString(vin.TxID)].ID == nil {
-			log.Panic("ERROR: Invalid Previous Tx List")
+	for i, vin := range tx.Vin {
+		prevTx := prevTxs[hex.EncodeToString(vin.TxID)]
+		if prevTx.ID == nil || vin.Vout < 0 || vin.Vout >= len(prevTx.Vout) {
+			return 0, fmt.Errorf("%w: input %d spends %x:%d, which isn't in the previous txs", ErrInvalidTransaction, i, vin.TxID, vin.Vout)
 		}
 	}
-	for i, in := range txCopy.Vin {
-		prevTx := prevTxs[hex.EncodeToString(in.TxID)]
-		txCopy.Vin[i].Signature = nil
-		txCopy.Vin[i].PubKey = prevTx.Vout[in.Vout].PubKeyHash // Set the pubkey in order to hash accurately w/ prev output
-		txCopy.ID = txCopy.Hash()
-		txCopy.Vin[i].PubKey = nil // Don't need that anymore
-
-		r, s, err := ecdsa.Sign(rand.Reader, &privateKey, txCopy.ID)
-		util.CheckAnxiety(err)
+	pubKeyHash := wallet.HashPublicKey(pubKey)
+	for i, in := range tx.Vin {
+		if hashType.base() == SigHashSingle && i >= len(tx.Vout) {
+			continue
+		}
+		prevOut := prevTxs[hex.EncodeToString(in.TxID)].Vout[in.Vout]
+		if prevOut.ScriptPubKey.ScriptHash() != nil {
+			ok, err := tx.signMultiSig(i, privateKey, pubKey, prevOut, hashType)
+			if err != nil {
+				return signed, fmt.Errorf("%w: input %d: %v", ErrInvalidTransaction, i, err)
+			}
+			if ok {
+				signed++
+			}
+			continue
+		}
+		if !prevOut.Unlockable(pubKeyHash) {
+			continue
+		}
 
-		signature := append(r.Bytes(), s.Bytes()...)
-		tx.Vin[i].Signature = signature
+		signature, err := tx.signInput(i, prevOut.ScriptPubKey, privateKey, hashType)
+		if err != nil {
+			return signed, fmt.Errorf("%w: input %d: %v", ErrInvalidTransaction, i, err)
+		}
+		tx.Vin[i].ScriptSig = NewP2PKHScriptSig(signature, pubKey)
+		signed++
 	}
+	return signed, nil
 }
 
-// Verify checks the given transaction and verifies every input's signature
+// Verify checks that every input of the given transaction unlocks the output it spends
 func (tx *Transaction) Verify(prevTxs map[string]Transaction) bool {
-	txCopy := tx.TrimmedCopy()
-	curve := elliptic.P256()
-
+	if tx.IsCoinbaseTx() {
+		return true
+	}
 	for i, in := range tx.Vin {
-		// Generate a hash for the txCopy using the referenced output's public key hash as the pubkey.
-		prevTx := prevTxs[hex.EncodeToString(in.TxID)]
-		txCopy.Vin[i].Signature = nil
-		txCopy.Vin[i].PubKey = prevTx.Vout[in.Vout].PubKeyHash
-		txCopy.ID = txCopy.Hash()
-		txCopy.Vin[i].PubKey = nil
-
-		// Take the input's signature and split it into it's pair
-		r := big.Int{}
-		s := big.Int{}
-		sigLength := len(in.Signature)
-		r.SetBytes(in.Signature[:sigLength/2])
-		s.SetBytes(in.Signature[sigLength/2:])
-
-		// Take the public key of the input and split it, then create a new raw public key from it
-		x := big.Int{}
-		y := big.Int{}
-		keyLength := len(in.PubKey)
-		x.SetBytes(in.PubKey[:keyLength/2])
-		y.SetBytes(in.PubKey[keyLength/2:])
-		rawPubKey := ecdsa.PublicKey{Curve: curve, X: &x, Y: &y}
-
-		// Check if the raw public key
-		if !ecdsa.Verify(&rawPubKey, txCopy.ID, &r, &s) {
+		prevTx, ok := prevTxs[hex.EncodeToString(in.TxID)]
+		if !ok || in.Vout < 0 || in.Vout >= len(prevTx.Vout) {
+			return false
+		}
+		if tx.VerifyInput(i, prevTx.Vout[in.Vout]) != nil {
 			return false
 		}
 	}
-
 	return true
 }
 
-// NewTransaction returns a new transaction
-func NewTransaction(bc *Blockchain, wallets *wallet.Wallets, to, from string, amount int) *Transaction {
-	var vin []TxInput
-	vout := []TxOutput{*NewUTXO(amount, to)}
+// NewTransaction returns a new transaction paying amount to the recipient and leaving fee for the miner.
+// Returns wallet.ErrWalletNotFound if from isn't one of the wallets' addresses and an error wrapping
+// ErrInsufficientFunds if it can't cover amount and fee.
+func NewTransaction(bc *Blockchain, wallets *wallet.Wallets, to, from string, amount, fee int) (*Transaction, error) {
+	fromWallet, err := wallets.GetWallet(from)
+	if err != nil {
+		return nil, err
+	}
+	newTx, err := newPayment(bc, wallet.HashPublicKey(fromWallet.PublicKey), from, to, amount, fee)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = bc.SignTransaction(newTx, &fromWallet, SigHashAll); err != nil {
+		return nil, err
+	}
+	return newTx, nil
+}
+
+// newPayment returns an unsigned transaction paying amount to the recipient from outputs locked to fromHash,
+// the hash in the from address, with the change going back to the from address
+func newPayment(bc *Blockchain, fromHash []byte, from, to string, amount, fee int) (*Transaction, error) {
+	totalIn, usedTxOutputs, err := FindUTXOsForPayment(bc, fromHash, amount+fee, nil)
+	if err != nil {
+		return nil, err
+	}
+	return buildPayment(bc.Network, totalIn, usedTxOutputs, from, to, amount, fee)
+}
 
-	fromWallet := wallets.GetWallet(from)
-	fromAddress := fromWallet.GetChromaAddress()
-	fromPubKeyHash := base58.Decode(string(fromAddress))
-	fromPubKeyHash = fromPubKeyHash[1 : len(fromPubKeyHash)-4]
-	totalIn, usedTxOutputs := FindUTXOsForPayment(bc, fromPubKeyHash, amount)
+// NewPaymentFromCoins returns a transaction paying amount to the recipient from coins found by FindCoins, e.g. on a
+// node, leaving fee for the miner and sending the change back to the wallet. It's signed by the wallet, so the chain
+// the coins came from isn't needed. Returns an error wrapping ErrInsufficientFunds if coins can't cover amount and fee.
+func NewPaymentFromCoins(network *config.Network, w *wallet.Wallet, coins Coins, to string, amount, fee int) (*Transaction, error) {
+	from := string(w.GetChromaAddress(network))
+	newTx, err := buildPayment(network, coins.Total, coins.Outputs, from, to, amount, fee)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = newTx.Sign(w.PrivateKey, w.PublicKey, coins.Txs, SigHashAll); err != nil {
+		return nil, err
+	}
+	return newTx, nil
+}
+
+// buildPayment returns an unsigned transaction paying amount to the recipient from the outputs found by
+// FindUTXOsForPayment, which add up to totalIn, with the change going back to the from address
+func buildPayment(network *config.Network, totalIn int, usedTxOutputs map[string][]int, from, to string, amount, fee int) (*Transaction, error) {
+	var vin []TxInput
+	out, err := NewUTXO(network, amount, to)
+	if err != nil {
+		return nil, err
+	}
+	vout := []TxOutput{*out}
 
-	if totalIn < amount {
-		log.Panic(fmt.Printf("Insufficient Funds: Found %d and needed at least %d\nTx Found: %+v\n", totalIn, amount, usedTxOutputs))
+	needed := amount + fee
+	if totalIn < needed {
+		return nil, fmt.Errorf("%w: found %d and needed at least %d", ErrInsufficientFunds, totalIn, needed)
 	}
 
-	for txID, outputs := range usedTxOutputs {
+	// Inputs go in txid and index order so the same payment always makes the same tx
+	var txIDs []string
+	for txID := range usedTxOutputs {
+		txIDs = append(txIDs, txID)
+	}
+	sort.Strings(txIDs)
+	for _, txID := range txIDs {
 		txIDBytes, err := hex.DecodeString(txID)
-		util.CheckAnxiety(err)
-		for _, outputIndex := range outputs {
-			input := TxInput{Vout: outputIndex, Signature: nil, PubKey: fromWallet.PublicKey, TxID: txIDBytes}
+		if err != nil {
+			return nil, err
+		}
+		for _, outputIndex := range usedTxOutputs[txID] {
+			input := TxInput{Vout: outputIndex, ScriptSig: nil, TxID: txIDBytes}
 			vin = append(vin, input)
 		}
 	}
 
-	if totalIn > amount {
-		change := *NewUTXO(totalIn-amount, from)
-		vout = append(vout, change)
+	// Whatever isn't sent back as change is the fee
+	if totalIn > needed {
+		change, err := NewUTXO(network, totalIn-needed, from)
+		if err != nil {
+			return nil, err
+		}
+		vout = append(vout, *change)
 	}
 
 	newTx := Transaction{Vin: vin, Vout: vout}
 	newTx.ID = newTx.Hash()
-	bc.SignTransaction(&newTx, fromWallet.PrivateKey)
-
-	return &newTx
+	return &newTx, nil
 }
 
-// NewCoinbaseTx returns a special TX to be awarded for mining a block.
-func NewCoinbaseTx(to, data string) *Transaction {
+// NewCoinbaseTx returns a special TX to be awarded for mining the block at the given height on the network,
+// paying its subsidy plus the fees of the block's other TXs.
+// Returns an error wrapping wallet.ErrInvalidAddress if to isn't a valid address.
+func NewCoinbaseTx(network *config.Network, to, data string, height, fees int) (*Transaction, error) {
 	// Fill pubkey with random data
 	if data == "" {
 		randomData := make([]byte, 20)
@@ -187,9 +274,12 @@ func NewCoinbaseTx(to, data string) *Transaction {
 		util.CheckAnxiety(err)
 		data = string(randomData)
 	}
-	txin := TxInput{TxID: []byte{}, Vout: -1, Signature: nil, PubKey: []byte(data)}
-	txout := *NewUTXO(conf.TXcoinbaseaward, to)
-	tx := Transaction{ID: nil, Vin: []TxInput{txin}, Vout: []TxOutput{txout}}
+	txin := TxInput{TxID: []byte{}, Vout: -1, ScriptSig: Script(data)}
+	txout, err := NewUTXO(network, GetBlockSubsidy(network, height)+fees, to)
+	if err != nil {
+		return nil, err
+	}
+	tx := Transaction{ID: nil, Vin: []TxInput{txin}, Vout: []TxOutput{*txout}}
 	tx.ID = tx.Hash()
-	return &tx
+	return &tx, nil
 }
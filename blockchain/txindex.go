@@ -0,0 +1,58 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+
+	conf "github.com/casalettoj/chroma/constants"
+	util "github.com/casalettoj/chroma/utils"
+	bolt "github.com/coreos/bbolt"
+)
+
+// TxLocation is where a transaction of the main chain is stored: the block holding it and its index in that block
+type TxLocation struct {
+	BlockHash []byte
+	Index     int
+}
+
+// Serialize returns a byte array serialization
+func (l *TxLocation) Serialize() []byte {
+	var result bytes.Buffer
+	encoder := gob.NewEncoder(&result)
+	util.CheckAnxiety(encoder.Encode(l))
+	return result.Bytes()
+}
+
+// DeserializeTxLocation deserializes a byte array into a TxLocation struct
+func DeserializeTxLocation(bbytes []byte) (*TxLocation, error) {
+	var location TxLocation
+	decoder := gob.NewDecoder(bytes.NewReader(bbytes))
+	if err := decoder.Decode(&location); err != nil {
+		return nil, fmt.Errorf("%w: tx location: %v", ErrCorruptData, err)
+	}
+	return &location, nil
+}
+
+// indexTransactions records where each of a newly connected block's transactions is stored
+func indexTransactions(tx *bolt.Tx, b *Block) error {
+	bucket := tx.Bucket([]byte(conf.DBtxbucket))
+	for i, transaction := range b.Transactions {
+		location := TxLocation{BlockHash: b.Hash, Index: i}
+		if err := bucket.Put(transaction.ID, location.Serialize()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unindexTransactions forgets a disconnected block's transactions
+func unindexTransactions(tx *bolt.Tx, b *Block) error {
+	bucket := tx.Bucket([]byte(conf.DBtxbucket))
+	for _, transaction := range b.Transactions {
+		if err := bucket.Delete(transaction.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
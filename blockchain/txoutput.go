@@ -3,39 +3,82 @@ package blockchain
 import (
 	"bytes"
 	"encoding/gob"
+	"fmt"
+	"sort"
 
-	"github.com/btcsuite/btcutil/base58"
+	"github.com/casalettoj/chroma/config"
 	util "github.com/casalettoj/chroma/utils"
+	wallet "github.com/casalettoj/chroma/wallet"
 )
 
-// TxOutput represents a transaction output
+// TxOutput represents a transaction output: an amount and the script locking it
 type TxOutput struct {
-	Value      int
-	PubKeyHash []byte
+	Value        int
+	ScriptPubKey Script
 }
 
-// LockTxO locks a TxO to a specific public key hash (1:len-4 bytes)
-func (txo *TxOutput) LockTxO(address []byte) {
-	pubKeyHash := base58.Decode(string(address[:]))
-	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4] //Start at 1 for the version byte, stop 4 early for the checksum bytes
-	txo.PubKeyHash = pubKeyHash
+// LockTxO locks a TxO to the hash in an address of the network: a public key hash with a P2PKH script,
+// or a script hash with a P2SH script. Returns an error wrapping wallet.ErrInvalidAddress if the address isn't valid.
+func (txo *TxOutput) LockTxO(network *config.Network, address []byte) error {
+	hash, isScript, err := wallet.DecodeAddress(network, string(address))
+	if err != nil {
+		return err
+	}
+	if isScript {
+		txo.ScriptPubKey = NewP2SHScript(hash)
+		return nil
+	}
+	txo.ScriptPubKey = NewP2PKHScript(hash)
+	return nil
 }
 
-// Unlockable returns whether the output can be unlocked by a given address
-func (txo *TxOutput) Unlockable(pubKeyHash []byte) bool {
-	return bytes.Compare(txo.PubKeyHash, pubKeyHash) == 0
+// Unlockable returns whether the output is locked to the hash in a given address, with a P2PKH or P2SH script
+func (txo *TxOutput) Unlockable(hash []byte) bool {
+	lock := txo.ScriptPubKey.PubKeyHash()
+	if lock == nil {
+		lock = txo.ScriptPubKey.ScriptHash()
+	}
+	return lock != nil && bytes.Compare(lock, hash) == 0
 }
 
-// NewUTXO creates a new transaction for a value and pubkeyhash string
-func NewUTXO(value int, address string) (utxo *TxOutput) {
-	utxo = &TxOutput{Value: value, PubKeyHash: nil}
-	utxo.LockTxO([]byte(address))
-	return
+// NewUTXO creates a new output paying value to an address of the network, or returns an error wrapping
+// wallet.ErrInvalidAddress if the address isn't valid
+func NewUTXO(network *config.Network, value int, address string) (*TxOutput, error) {
+	utxo := &TxOutput{Value: value, ScriptPubKey: nil}
+	if err := utxo.LockTxO(network, []byte(address)); err != nil {
+		return nil, err
+	}
+	return utxo, nil
 }
 
-// TxOutputs is a structure containing an array of TXOs
+// TxOutputs is a structure containing the TXOs of a transaction keyed by their index in its Vout,
+// along with the height of the block the transaction is in and whether it is a coinbase tx
 type TxOutputs struct {
-	Outputs []TxOutput
+	Outputs  map[int]TxOutput
+	Height   int
+	Coinbase bool
+}
+
+// UTXOEntry is a single unspent output along with the height of the block that created it and whether a coinbase tx did
+type UTXOEntry struct {
+	Output   TxOutput
+	Height   int
+	Coinbase bool
+}
+
+// IsMature returns whether the output may be spent by a tx in the block at the given height on the network
+func (e *UTXOEntry) IsMature(network *config.Network, height int) bool {
+	return !e.Coinbase || height-e.Height >= network.CoinbaseMaturity
+}
+
+// Indexes returns the indexes of the outputs in ascending order
+func (txos *TxOutputs) Indexes() []int {
+	var indexes []int
+	for index := range txos.Outputs {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	return indexes
 }
 
 // Serialize returns a byte array serialization
@@ -47,9 +90,14 @@ func (txos *TxOutputs) Serialize() []byte {
 }
 
 // DeserializeTxOutputs deserializes a byte array into a TxOutputs struct
-func DeserializeTxOutputs(bbytes []byte) *TxOutputs {
+func DeserializeTxOutputs(bbytes []byte) (*TxOutputs, error) {
 	var txOutputs TxOutputs
 	decoder := gob.NewDecoder(bytes.NewReader(bbytes))
-	util.CheckAnxiety(decoder.Decode(&txOutputs))
-	return &txOutputs
+	if err := decoder.Decode(&txOutputs); err != nil {
+		return nil, fmt.Errorf("%w: tx outputs: %v", ErrCorruptData, err)
+	}
+	if txOutputs.Outputs == nil {
+		txOutputs.Outputs = make(map[int]TxOutput)
+	}
+	return &txOutputs, nil
 }
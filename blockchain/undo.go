@@ -0,0 +1,33 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+
+	util "github.com/casalettoj/chroma/utils"
+)
+
+// BlockUndo holds what's needed to roll a block back out of the UTXO set:
+// Spent[i][j] is the output that input j of the block's transaction i spent.
+type BlockUndo struct {
+	Spent [][]UTXOEntry
+}
+
+// Serialize returns a byte array serialization
+func (u *BlockUndo) Serialize() []byte {
+	var result bytes.Buffer
+	encoder := gob.NewEncoder(&result)
+	util.CheckAnxiety(encoder.Encode(u))
+	return result.Bytes()
+}
+
+// DeserializeBlockUndo deserializes a byte array into a BlockUndo struct
+func DeserializeBlockUndo(bbytes []byte) (*BlockUndo, error) {
+	var undo BlockUndo
+	decoder := gob.NewDecoder(bytes.NewReader(bbytes))
+	if err := decoder.Decode(&undo); err != nil {
+		return nil, fmt.Errorf("%w: undo data: %v", ErrCorruptData, err)
+	}
+	return &undo, nil
+}
@@ -2,47 +2,123 @@ package blockchain
 
 import (
 	"encoding/hex"
+	"fmt"
 
 	conf "github.com/casalettoj/chroma/constants"
-	util "github.com/casalettoj/chroma/utils"
 	bolt "github.com/coreos/bbolt"
 )
 
-// FindUTXOsForPayment searches through the UTXOSet for unlockable UTXOs until the amount is reached
+// FindUTXOsForPayment searches through the UTXOSet for unlockable UTXOs until the amount is reached,
+// skipping coinbase outputs that can't be spent in the next block yet and those spent reports as spent already,
+// e.g. by a pooled tx (see Mempool.Spends). spent may be nil. Outputs are taken in txid and index order,
+// so the same UTXO set always gives the same selection.
 // returns the amount of all retrieved UTXOs and a map of TxIDs and UTXO indices
-func FindUTXOsForPayment(bc *Blockchain, pubKeyHash []byte, amount int) (accumulated int, UTXOIndices map[string][]int) {
+func FindUTXOsForPayment(bc *Blockchain, pubKeyHash []byte, amount int, spent func(txID []byte, vout int) bool) (accumulated int, UTXOIndices map[string][]int, err error) {
 	UTXOIndices = make(map[string][]int)
 	db := bc.DB
+	bestHeight, err := bc.GetBestHeight()
+	if err != nil {
+		return 0, nil, err
+	}
+	nextHeight := bestHeight + 1
 
-	util.CheckAnxiety(db.View(func(tx *bolt.Tx) error {
+	err = db.View(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(conf.DButxobucket))
 		cursor := bucket.Cursor()
-		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+		for k, v := cursor.First(); k != nil && accumulated < amount; k, v = cursor.Next() {
 			txID := hex.EncodeToString(k)
-			UTXOs := DeserializeTxOutputs(v)
-			for UTXOIndex, UTXO := range UTXOs.Outputs {
-				if accumulated > amount {
+			UTXOs, err := DeserializeTxOutputs(v)
+			if err != nil {
+				return err
+			}
+			entry := UTXOEntry{Height: UTXOs.Height, Coinbase: UTXOs.Coinbase}
+			if !entry.IsMature(bc.Network, nextHeight) {
+				continue
+			}
+			for _, UTXOIndex := range UTXOs.Indexes() {
+				if accumulated >= amount {
 					break
 				}
-				if UTXO.Unlockable(pubKeyHash) {
+				if spent != nil && spent(k, UTXOIndex) {
+					continue
+				}
+				if UTXO := UTXOs.Outputs[UTXOIndex]; UTXO.Unlockable(pubKeyHash) {
 					accumulated += UTXO.Value
 					UTXOIndices[txID] = append(UTXOIndices[txID], UTXOIndex)
 				}
 			}
 		}
 		return nil
-	}))
+	})
 	return
 }
 
+// Coins are the outputs FindCoins found to pay from, along with the transactions holding them so they can be signed
+// without the chain
+type Coins struct {
+	// Total is the value of every output found
+	Total int
+	// Outputs maps the hex TxID of each transaction to the indexes of its outputs found
+	Outputs map[string][]int
+	// Txs maps the hex TxID of each transaction to the transaction
+	Txs map[string]Transaction
+}
+
+// FindCoins finds unlockable outputs as FindUTXOsForPayment does, returning them with their transactions.
+// Outputs are found until their total reaches amount, or every one there is was found.
+func FindCoins(bc *Blockchain, pubKeyHash []byte, amount int, spent func(txID []byte, vout int) bool) (Coins, error) {
+	total, outputs, err := FindUTXOsForPayment(bc, pubKeyHash, amount, spent)
+	if err != nil {
+		return Coins{}, err
+	}
+	coins := Coins{Total: total, Outputs: outputs, Txs: make(map[string]Transaction)}
+	for txID := range outputs {
+		ID, err := hex.DecodeString(txID)
+		if err != nil {
+			return Coins{}, err
+		}
+		if coins.Txs[txID], err = bc.FindTransaction(ID); err != nil {
+			return Coins{}, err
+		}
+	}
+	return coins, nil
+}
+
+// GetUTXO returns the unspent output at index vout of the transaction txID, and whether it is in the UTXO set at all
+func GetUTXO(bc *Blockchain, txID []byte, vout int) (UTXO UTXOEntry, found bool, err error) {
+	err = bc.DB.View(func(tx *bolt.Tx) error {
+		UTXO, found, err = getUTXO(tx, txID, vout)
+		return err
+	})
+	return
+}
+
+// getUTXO does the bolt side of GetUTXO, so it can also see the UTXO set in the middle of an update
+func getUTXO(tx *bolt.Tx, txID []byte, vout int) (UTXO UTXOEntry, found bool, err error) {
+	encodedUTXOs := tx.Bucket([]byte(conf.DButxobucket)).Get(txID)
+	if encodedUTXOs == nil {
+		return UTXOEntry{}, false, nil
+	}
+	UTXOs, err := DeserializeTxOutputs(encodedUTXOs)
+	if err != nil {
+		return UTXOEntry{}, false, err
+	}
+	UTXO = UTXOEntry{Height: UTXOs.Height, Coinbase: UTXOs.Coinbase}
+	UTXO.Output, found = UTXOs.Outputs[vout]
+	return UTXO, found, nil
+}
+
 // GetUTXOsForAddress returns all unspent tx outputs for a given address
-func GetUTXOsForAddress(bc *Blockchain, pubKeyHash []byte) (UTXOs []TxOutput) {
+func GetUTXOsForAddress(bc *Blockchain, pubKeyHash []byte) (UTXOs []TxOutput, err error) {
 	db := bc.DB
-	util.CheckAnxiety(db.View(func(tx *bolt.Tx) error {
+	err = db.View(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(conf.DButxobucket))
 		cursor := bucket.Cursor()
 		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
-			utxoutputs := DeserializeTxOutputs(v)
+			utxoutputs, err := DeserializeTxOutputs(v)
+			if err != nil {
+				return err
+			}
 			for _, utxo := range utxoutputs.Outputs {
 				if utxo.Unlockable(pubKeyHash) {
 					UTXOs = append(UTXOs, utxo)
@@ -50,71 +126,139 @@ func GetUTXOsForAddress(bc *Blockchain, pubKeyHash []byte) (UTXOs []TxOutput) {
 			}
 		}
 		return nil
-	}))
+	})
 	return
 }
 
-// ReindexUTXOs deletes the current UTXO set from db and creates a new set
-func ReindexUTXOs(bc *Blockchain) {
+// GetCirculatingSupply returns the total value of every unspent output in the UTXO set
+func GetCirculatingSupply(bc *Blockchain) (supply int, err error) {
 	db := bc.DB
-	util.CheckAnxiety(db.Update(func(tx *bolt.Tx) error {
-		err := tx.DeleteBucket([]byte(conf.DButxobucket))
-		if err != bolt.ErrBucketNotFound {
-			util.CheckAnxiety(err)
-		}
-		_, err = tx.CreateBucket([]byte(conf.DButxobucket))
-		util.CheckAnxiety(err)
-		return nil
-	}))
-	UTXOsByTxID := bc.GetUTXOs()
-	util.CheckAnxiety(db.Update(func(tx *bolt.Tx) error {
+	err = db.View(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(conf.DButxobucket))
+		return bucket.ForEach(func(k, v []byte) error {
+			UTXOs, err := DeserializeTxOutputs(v)
+			if err != nil {
+				return err
+			}
+			for _, utxo := range UTXOs.Outputs {
+				supply += utxo.Value
+			}
+			return nil
+		})
+	})
+	return
+}
+
+// ReindexUTXOs deletes the current UTXO set from db and creates a new set
+func ReindexUTXOs(bc *Blockchain) error {
+	UTXOsByTxID, err := bc.GetUTXOs()
+	if err != nil {
+		return err
+	}
+	return bc.DB.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket([]byte(conf.DButxobucket)); err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+		bucket, err := tx.CreateBucket([]byte(conf.DButxobucket))
+		if err != nil {
+			return err
+		}
 		for txID, utxos := range UTXOsByTxID {
 			key, err := hex.DecodeString(txID)
-			util.CheckAnxiety(err)
-			util.CheckAnxiety(bucket.Put(key, utxos.Serialize()))
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put(key, utxos.Serialize()); err != nil {
+				return err
+			}
 		}
 		return nil
-	}))
+	})
 }
 
-// UpdateUTXOs takes the newest block, removes all outputs that were used as inputs in its transactions
-// and adds the outputs of each Tx as new UTXOs in the set.
-func UpdateUTXOs(bc *Blockchain, b *Block) {
-	db := bc.DB
-	util.CheckAnxiety(db.Update(func(tx *bolt.Tx) error {
-		utxoBucket := tx.Bucket([]byte(conf.DButxobucket))
-		for _, transaction := range b.Transactions {
-			// If the tx is a coinbase tx, ignore the inputs entirely
-			if !transaction.IsCoinbaseTx() {
-				for _, input := range transaction.Vin {
-					// Check the last TX's output's index and if it wasn't the index used in the input (Vout)
-					// then it is still unspent and should be in the new UTXOs of the last TX.
-					updatedUTXOs := TxOutputs{}
-					prevTxUTXOsBytes := utxoBucket.Get(input.TxID)
-					prevTxUTXOs := DeserializeTxOutputs(prevTxUTXOsBytes)
-					for prevUTXOIndex, prevUTXO := range prevTxUTXOs.Outputs {
-						if input.Vout != prevUTXOIndex {
-							updatedUTXOs.Outputs = append(updatedUTXOs.Outputs, prevUTXO)
-						}
-					}
-					// Then if the TX has no more UTXOs remove it from the bucket
-					// Otherwise, update the TXID-indexed TxOutputs with the updated structure
-					if len(updatedUTXOs.Outputs) == 0 {
-						util.CheckAnxiety(utxoBucket.Delete(input.TxID))
-					} else {
-						util.CheckAnxiety(utxoBucket.Put(input.TxID, updatedUTXOs.Serialize()))
-					}
+// connectUTXOs takes the newest block, removes all outputs that were used as inputs in its transactions
+// and adds the outputs of each Tx as new UTXOs in the set, all within a bolt transaction.
+// The spent outputs are saved in the undo bucket so disconnectUTXOs can roll the block back.
+func connectUTXOs(tx *bolt.Tx, b *Block) error {
+	utxoBucket := tx.Bucket([]byte(conf.DButxobucket))
+	undo := BlockUndo{Spent: make([][]UTXOEntry, len(b.Transactions))}
+	for txIndex, transaction := range b.Transactions {
+		// If the tx is a coinbase tx, ignore the inputs entirely
+		if !transaction.IsCoinbaseTx() {
+			for _, input := range transaction.Vin {
+				// Drop the output used by the input (Vout) from the last TX's UTXOs; the rest are still unspent.
+				prevTxUTXOsBytes := utxoBucket.Get(input.TxID)
+				updatedUTXOs, err := DeserializeTxOutputs(prevTxUTXOsBytes)
+				if err != nil {
+					return err
+				}
+				spent := UTXOEntry{updatedUTXOs.Outputs[input.Vout], updatedUTXOs.Height, updatedUTXOs.Coinbase}
+				undo.Spent[txIndex] = append(undo.Spent[txIndex], spent)
+				delete(updatedUTXOs.Outputs, input.Vout)
+				// Then if the TX has no more UTXOs remove it from the bucket
+				// Otherwise, update the TXID-indexed TxOutputs with the updated structure
+				if len(updatedUTXOs.Outputs) == 0 {
+					err = utxoBucket.Delete(input.TxID)
+				} else {
+					err = utxoBucket.Put(input.TxID, updatedUTXOs.Serialize())
+				}
+				if err != nil {
+					return err
 				}
 			}
+		}
 
-			// Next, place all of the new TxOutputs from the new block into the UTXOset
-			newUTXOs := TxOutputs{}
-			for _, output := range transaction.Vout {
-				newUTXOs.Outputs = append(newUTXOs.Outputs, output)
+		// Next, place all of the new TxOutputs from the new block into the UTXOset, leaving out ones that can't be spent
+		newUTXOs := TxOutputs{Outputs: make(map[int]TxOutput), Height: b.Height, Coinbase: transaction.IsCoinbaseTx()}
+		for outIndex, output := range transaction.Vout {
+			if !output.ScriptPubKey.IsUnspendable() {
+				newUTXOs.Outputs[outIndex] = output
 			}
-			util.CheckAnxiety(utxoBucket.Put(transaction.ID, newUTXOs.Serialize()))
 		}
-		return nil
-	}))
+		if len(newUTXOs.Outputs) > 0 {
+			if err := utxoBucket.Put(transaction.ID, newUTXOs.Serialize()); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Bucket([]byte(conf.DBundobucket)).Put(b.Hash, undo.Serialize())
+}
+
+// disconnectUTXOs rolls a block back out of the UTXO set within a bolt transaction using its undo data:
+// the outputs its transactions created are removed and the outputs they spent are restored.
+func disconnectUTXOs(tx *bolt.Tx, b *Block) error {
+	utxoBucket := tx.Bucket([]byte(conf.DButxobucket))
+	encodedUndo := tx.Bucket([]byte(conf.DBundobucket)).Get(b.Hash)
+	if encodedUndo == nil {
+		return fmt.Errorf("%w: no undo data for block %x", ErrCorruptData, b.Hash)
+	}
+	undo, err := DeserializeBlockUndo(encodedUndo)
+	if err != nil {
+		return err
+	}
+
+	// Go backwards so outputs created and spent within the block end up removed
+	for txIndex := len(b.Transactions) - 1; txIndex >= 0; txIndex-- {
+		transaction := b.Transactions[txIndex]
+		if err := utxoBucket.Delete(transaction.ID); err != nil {
+			return err
+		}
+		if transaction.IsCoinbaseTx() {
+			continue
+		}
+		for inIndex, input := range transaction.Vin {
+			spent := undo.Spent[txIndex][inIndex]
+			restoredUTXOs := &TxOutputs{Outputs: make(map[int]TxOutput), Height: spent.Height, Coinbase: spent.Coinbase}
+			if encodedUTXOs := utxoBucket.Get(input.TxID); encodedUTXOs != nil {
+				if restoredUTXOs, err = DeserializeTxOutputs(encodedUTXOs); err != nil {
+					return err
+				}
+			}
+			restoredUTXOs.Outputs[input.Vout] = spent.Output
+			if err := utxoBucket.Put(input.TxID, restoredUTXOs.Serialize()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
@@ -0,0 +1,297 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/casalettoj/chroma/config"
+	conf "github.com/casalettoj/chroma/constants"
+	bolt "github.com/coreos/bbolt"
+)
+
+// ValidationError describes the first block that failed validation and why
+type ValidationError struct {
+	Height int
+	Hash   []byte
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("block %d (%x) is invalid: %s", e.Height, e.Hash, e.Reason)
+}
+
+// utxoView is an in-memory overlay of the UTXO set that transactions are checked and applied against.
+// Outputs it hasn't seen are looked up in bc's UTXO bucket, or tx's in the middle of an update, unless both are nil.
+type utxoView struct {
+	bc      *Blockchain
+	tx      *bolt.Tx
+	created map[string]UTXOEntry
+	spent   map[string]bool
+}
+
+// newUTXOView returns an empty view, backed by bc's UTXO bucket if bc isn't nil
+func newUTXOView(bc *Blockchain) *utxoView {
+	return &utxoView{bc: bc, created: make(map[string]UTXOEntry), spent: make(map[string]bool)}
+}
+
+// newTxUTXOView returns an empty view backed by the UTXO bucket as tx sees it
+func newTxUTXOView(tx *bolt.Tx) *utxoView {
+	return &utxoView{tx: tx, created: make(map[string]UTXOEntry), spent: make(map[string]bool)}
+}
+
+// get returns the unspent output at index vout of the transaction txID, if the view has one
+func (v *utxoView) get(txID []byte, vout int) (UTXOEntry, bool, error) {
+	key := outpoint(txID, vout)
+	if v.spent[key] {
+		return UTXOEntry{}, false, nil
+	}
+	if UTXO, ok := v.created[key]; ok {
+		return UTXO, true, nil
+	}
+	if v.tx != nil {
+		return getUTXO(v.tx, txID, vout)
+	}
+	if v.bc != nil {
+		return GetUTXO(v.bc, txID, vout)
+	}
+	return UTXOEntry{}, false, nil
+}
+
+// apply spends a transaction's inputs and adds its outputs to the view as created at the given height
+func (v *utxoView) apply(tx *Transaction, height int) {
+	if !tx.IsCoinbaseTx() {
+		for _, in := range tx.Vin {
+			key := outpoint(in.TxID, in.Vout)
+			delete(v.created, key)
+			v.spent[key] = true
+		}
+	}
+	for outIndex, out := range tx.Vout {
+		if !out.ScriptPubKey.IsUnspendable() {
+			v.created[outpoint(tx.ID, outIndex)] = UTXOEntry{out, height, tx.IsCoinbaseTx()}
+		}
+	}
+}
+
+// Validate walks the chain from genesis to the tip, re-checking every block and transaction against the
+// consensus rules and an in-memory UTXO set. Returns a *ValidationError for the first block that fails.
+func (bc *Blockchain) Validate() error {
+	hashes, err := bc.GetBlockHashes()
+	if err != nil {
+		return err
+	}
+	view := newUTXOView(nil)
+	var prev *Block
+
+	for height := 0; height < len(hashes); height++ {
+		hash := hashes[len(hashes)-1-height]
+		block, err := bc.GetBlock(hash)
+		if err != nil {
+			return &ValidationError{height, hash, "missing from the blocks bucket"}
+		}
+		if bytes.Compare(block.Hash, hash) != 0 {
+			return &ValidationError{height, hash, fmt.Sprintf("stored under %x but hashes to %x", hash, block.Hash)}
+		}
+		if err = bc.checkBlock(&block, prev, view); err != nil {
+			return &ValidationError{height, hash, err.Error()}
+		}
+		prev = &block
+	}
+	return nil
+}
+
+// checkBlock checks a block that is to follow prev (nil for genesis) against the consensus rules,
+// applying its transactions to view as it goes.
+func (bc *Blockchain) checkBlock(block, prev *Block, view *utxoView) error {
+	if err := bc.checkHeader(block, prev); err != nil {
+		return err
+	}
+	return checkTransactions(bc.Network, block, view)
+}
+
+// checkHeader checks a block's linkage to prev and height, its difficulty, proof of work and timestamp
+func (bc *Blockchain) checkHeader(block, prev *Block) error {
+	if prev == nil {
+		if len(block.PrevHash) != 0 {
+			return errors.New("genesis block has a previous hash")
+		}
+		if block.Height != 0 {
+			return fmt.Errorf("genesis block has height %d", block.Height)
+		}
+	} else {
+		if bytes.Compare(block.PrevHash, prev.Hash) != 0 {
+			return fmt.Errorf("previous hash %x doesn't match %x", block.PrevHash, prev.Hash)
+		}
+		if block.Height != prev.Height+1 {
+			return fmt.Errorf("height %d should be %d", block.Height, prev.Height+1)
+		}
+		expected, err := bc.GetNextBits(prev)
+		if err != nil {
+			return err
+		}
+		if block.Bits != expected {
+			return fmt.Errorf("bits %08x should be %08x", block.Bits, expected)
+		}
+		medianTime, err := bc.medianTimePast(prev)
+		if err != nil {
+			return err
+		}
+		if block.Timestamp < medianTime {
+			return fmt.Errorf("timestamp %d is before the median time of the previous blocks (%d)", block.Timestamp, medianTime)
+		}
+	}
+	if CompactToTarget(block.Bits).Cmp(PowLimit(bc.Network)) > 0 {
+		return fmt.Errorf("bits %08x are easier than the network allows", block.Bits)
+	}
+	if !NewProofOfWork(block).IsValid() {
+		return errors.New("proof of work is invalid")
+	}
+	if block.Timestamp > time.Now().Unix()+conf.BLOCKmaxfutureseconds {
+		return fmt.Errorf("timestamp %d is too far in the future", block.Timestamp)
+	}
+	return nil
+}
+
+// checkTransactions checks a block's transactions in order against the network's rules, applying each to view
+// so later ones may spend it
+func checkTransactions(network *config.Network, block *Block, view *utxoView) error {
+	if len(block.Transactions) == 0 {
+		return errors.New("block has no transactions")
+	}
+
+	seen := make(map[string]bool)
+	coinbaseOut, fees := 0, 0
+	for i, tx := range block.Transactions {
+		txID := hex.EncodeToString(tx.ID)
+		if seen[txID] {
+			return fmt.Errorf("tx %s appears twice", txID)
+		}
+		seen[txID] = true
+		totalOut, err := checkOutputs(network, tx)
+		if err != nil {
+			return fmt.Errorf("tx %s %v", txID, err)
+		}
+
+		if i == 0 {
+			if !tx.IsCoinbaseTx() {
+				return errors.New("first tx isn't a coinbase tx")
+			}
+			coinbaseOut = totalOut
+			view.apply(tx, block.Height)
+			continue
+		}
+		if tx.IsCoinbaseTx() {
+			return fmt.Errorf("tx %s is a second coinbase tx", txID)
+		}
+
+		fee, err := checkInputs(network, tx, totalOut, view, block.Height)
+		if err != nil {
+			return fmt.Errorf("tx %s %v", txID, err)
+		}
+		if fees > MaxMoney(network)-fee {
+			return fmt.Errorf("txs pay more in fees than %d coins", MaxMoney(network))
+		}
+		fees += fee
+		view.apply(tx, block.Height)
+	}
+
+	// The coinbase can only be checked once the fees it may collect are known
+	subsidy := GetBlockSubsidy(network, block.Height)
+	if fees > MaxMoney(network)-subsidy {
+		return fmt.Errorf("subsidy of %d plus %d in fees is more than %d coins", subsidy, fees, MaxMoney(network))
+	}
+	if coinbaseOut > subsidy+fees {
+		return fmt.Errorf("coinbase tx %x pays %d, more than the subsidy of %d plus %d in fees",
+			block.Transactions[0].ID, coinbaseOut, subsidy, fees)
+	}
+	return nil
+}
+
+// checkOutputs checks that a transaction's ID matches its contents and that it has outputs which all carry value
+// (a coinbase tx's and unspendable data outputs may be empty), none of them nor their total more than the network's
+// MaxMoney. Returns the total value of the outputs.
+func checkOutputs(network *config.Network, tx *Transaction) (totalOut int, err error) {
+	if bytes.Compare(tx.ID, tx.ComputeID()) != 0 {
+		return 0, errors.New("has an ID that doesn't match its contents")
+	}
+	if len(tx.Vout) == 0 {
+		return 0, errors.New("has no outputs")
+	}
+	for _, out := range tx.Vout {
+		// Once the subsidy has run out a coinbase tx with no fees to collect can't pay anything
+		if out.Value < 0 || out.Value == 0 && !tx.IsCoinbaseTx() && !out.ScriptPubKey.IsUnspendable() {
+			return 0, errors.New("has an output with no value")
+		}
+		// Checked before adding so the total can't overflow
+		if out.Value > MaxMoney(network)-totalOut {
+			return 0, fmt.Errorf("pays out more than %d coins", MaxMoney(network))
+		}
+		totalOut += out.Value
+	}
+	return totalOut, nil
+}
+
+// checkInputs checks that a transaction to be mined at the given height on the network only spends unspent (and, for coinbase outputs,
+// mature) outputs, covers totalOut with them and unlocks each of them. Its lock time has to have been reached.
+// Returns the transaction's fee: whatever its inputs hold beyond totalOut.
+func checkInputs(network *config.Network, tx *Transaction, totalOut int, view *utxoView, height int) (fee int, err error) {
+	if len(tx.Vin) == 0 {
+		return 0, errors.New("has no inputs")
+	}
+
+	if tx.LockTime > height {
+		return 0, fmt.Errorf("is locked until height %d", tx.LockTime)
+	}
+
+	totalIn := 0
+	spent := make(map[string]bool)
+	for i, in := range tx.Vin {
+		key := outpoint(in.TxID, in.Vout)
+		if spent[key] {
+			return 0, fmt.Errorf("spends %s twice", key)
+		}
+		spent[key] = true
+		UTXO, found, err := view.get(in.TxID, in.Vout)
+		if err != nil {
+			return 0, err
+		}
+		if !found {
+			return 0, fmt.Errorf("spends %s which is missing or already spent", key)
+		}
+		if !UTXO.IsMature(network, height) {
+			return 0, fmt.Errorf("spends coinbase output %s from height %d before it matures", key, UTXO.Height)
+		}
+		if err := tx.VerifyInput(i, UTXO.Output); err != nil {
+			return 0, fmt.Errorf("input %d can't spend %s: %v", i, key, err)
+		}
+		if UTXO.Output.Value > MaxMoney(network)-totalIn {
+			return 0, fmt.Errorf("spends more than %d coins", MaxMoney(network))
+		}
+		totalIn += UTXO.Output.Value
+	}
+	if totalOut > totalIn {
+		return 0, fmt.Errorf("spends %d but only has %d in inputs", totalOut, totalIn)
+	}
+	return totalIn - totalOut, nil
+}
+
+// medianTimePast returns the median timestamp of the BLOCKmediantimespan blocks ending at block
+func (bc *Blockchain) medianTimePast(block *Block) (int64, error) {
+	timestamps := []int64{block.Timestamp}
+	bci := &Iterator{block.PrevHash, bc.DB}
+	for len(timestamps) < conf.BLOCKmediantimespan && !bci.IsGenesisBlock() {
+		prev, err := bci.Next()
+		if err != nil {
+			return 0, err
+		}
+		timestamps = append(timestamps, prev.Timestamp)
+	}
+	sort.Slice(timestamps, func(i, j int) bool {
+		return timestamps[i] < timestamps[j]
+	})
+	return timestamps[len(timestamps)/2], nil
+}
@@ -0,0 +1,73 @@
+package blockchain
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/casalettoj/chroma/config"
+	"github.com/casalettoj/chroma/wallet"
+)
+
+func TestCheckOutputsMaxMoney(t *testing.T) {
+	max := MaxMoney(&config.Regtest)
+	tests := []struct {
+		name   string
+		values []int
+		valid  bool
+	}{
+		{"all the money", []int{max}, true},
+		{"split up to all the money", []int{max - 1, 1}, true},
+		{"more than all the money", []int{max + 1}, false},
+		{"adding up to more than all the money", []int{max, 1}, false},
+		{"wrapping around", []int{math.MaxInt64, math.MaxInt64}, false},
+		{"negative", []int{-1}, false},
+	}
+	lock := NewP2PKHScript(make([]byte, 20))
+	for _, test := range tests {
+		tx := &Transaction{Vin: []TxInput{{TxID: []byte{1}, Vout: 0}}}
+		for _, value := range test.values {
+			tx.Vout = append(tx.Vout, TxOutput{Value: value, ScriptPubKey: lock})
+		}
+		tx.ID = tx.Hash()
+		if _, err := checkOutputs(&config.Regtest, tx); (err == nil) != test.valid {
+			t.Errorf("%s: got %v", test.name, err)
+		}
+	}
+}
+
+func TestOverflowingTxRejected(t *testing.T) {
+	bc, w := newTestChain(t)
+	to := string(wallet.NewWallet(wallet.P256).GetChromaAddress(bc.Network))
+	genesis, err := bc.GetBlock(bc.Tip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = bc.Generate(bc.Network.CoinbaseMaturity, to); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two outputs of MaxInt64 add up to -2, which is less than the coinbase being spent
+	out, err := NewUTXO(bc.Network, math.MaxInt64, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := &Transaction{Vin: []TxInput{{TxID: genesis.Transactions[0].ID, Vout: 0}}, Vout: []TxOutput{*out, *out}}
+	tx.ID = tx.Hash()
+	if _, err = bc.SignTransaction(tx, w, SigHashAll); err != nil {
+		t.Fatal(err)
+	}
+	if err = NewMempool().Add(bc, tx); err == nil {
+		t.Error("the pool took a tx paying out more than exists")
+	}
+	if err = mineTx(bc, tx, to); !errors.Is(err, ErrInvalidTransaction) {
+		t.Errorf("mining a tx paying out more than exists gave %v, want ErrInvalidTransaction", err)
+	}
+	balance, err := bc.GetBalance(to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := bc.Network.CoinbaseMaturity * GetBlockSubsidy(bc.Network, 0); balance != want {
+		t.Errorf("got balance %d, want %d", balance, want)
+	}
+}
@@ -0,0 +1,14 @@
+package cli
+
+import (
+	"fmt"
+)
+
+// changePassphrase re-encrypts the wallet file with a new passphrase
+func changePassphrase(passphraseFile, newPassphraseFile string) {
+	wallets := openWallets(passphraseFile)
+	newPassphrase := readPassphrase(newPassphraseFile, "New wallet passphrase: ", true)
+	wallets.SetPassphrase(newPassphrase)
+	exitOnError(wallets.SaveWallets())
+	fmt.Println("Wallet passphrase changed.")
+}
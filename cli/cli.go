@@ -1,17 +1,34 @@
 package cli
 
 import (
+	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 
+	"github.com/casalettoj/chroma/blockchain"
+	"github.com/casalettoj/chroma/config"
 	conf "github.com/casalettoj/chroma/constants"
 	util "github.com/casalettoj/chroma/utils"
+	"github.com/casalettoj/chroma/wallet"
 )
 
+// settings says where the chain and wallet of the network in use are kept
+var settings *config.Config
+
 // Run runs cli flags
 func Run() {
-	validateArgs()
+	var args []string
+	var err error
+	settings, args, err = config.Load(os.Args[1:])
+	if err != nil {
+		fmt.Printf("Invalid settings: %v.\n", err)
+		failure()
+	}
+	validateArgs(args)
+
 	createBlockchainCommand := flag.NewFlagSet(conf.CLIcreateblockchain, flag.PanicOnError)
 	createAddress := createBlockchainCommand.String(conf.CLIaddress, "", "Reward Address")
 
@@ -19,35 +36,135 @@ func Run() {
 	balanceAddress := getBalanceCommand.String(conf.CLIaddress, "", "Balance Address")
 
 	printChainCommand := flag.NewFlagSet(conf.CLIprintchain, flag.PanicOnError)
+	printChainStart := printChainCommand.Int(conf.CLIstart, 0, "Height to print from")
+	printChainEnd := printChainCommand.Int(conf.CLIend, -1, "Height to print up to, the tip by default")
+
+	validateChainCommand := flag.NewFlagSet(conf.CLIvalidatechain, flag.PanicOnError)
+
+	reindexCommand := flag.NewFlagSet(conf.CLIreindex, flag.PanicOnError)
+
+	supplyCommand := flag.NewFlagSet(conf.CLIsupply, flag.PanicOnError)
 
 	sendCommand := flag.NewFlagSet(conf.CLIsend, flag.PanicOnError)
 	sendTo := sendCommand.String(conf.CLIto, "", "To Address")
 	sendFrom := sendCommand.String(conf.CLIfrom, "", "From Address")
 	sendAmount := sendCommand.Int(conf.CLIamount, 0, "Amout to send")
+	sendFee := sendCommand.Int(conf.CLIfee, 0, "Fee to leave for the miner")
+	sendNode := sendCommand.String(conf.CLInode, "", "Node to submit the transaction to instead of mining it locally")
+	sendPassphraseFile := sendCommand.String(conf.CLIpassphrasefile, "", "File holding the wallet passphrase, instead of prompting for it")
+
+	createMultiSigCommand := flag.NewFlagSet(conf.CLIcreatemultisig, flag.PanicOnError)
+	createMultiSigRequired := createMultiSigCommand.Int(conf.CLIrequired, 0, "Number of signatures needed to spend")
+	createMultiSigPubKeys := createMultiSigCommand.String(conf.CLIpubkeys, "", "Comma separated hex public keys of the signers")
+	createMultiSigPassphraseFile := createMultiSigCommand.String(conf.CLIpassphrasefile, "", "File holding the wallet passphrase, instead of prompting for it")
+
+	createMultiSigTxCommand := flag.NewFlagSet(conf.CLIcreatemultisigtx, flag.PanicOnError)
+	createMultiSigTxFrom := createMultiSigTxCommand.String(conf.CLIfrom, "", "Multisig Address")
+	createMultiSigTxTo := createMultiSigTxCommand.String(conf.CLIto, "", "To Address")
+	createMultiSigTxAmount := createMultiSigTxCommand.Int(conf.CLIamount, 0, "Amount to send")
+	createMultiSigTxFee := createMultiSigTxCommand.Int(conf.CLIfee, 0, "Fee to leave for the miner")
+	createMultiSigTxPassphraseFile := createMultiSigTxCommand.String(conf.CLIpassphrasefile, "", "File holding the wallet passphrase, instead of prompting for it")
+
+	signTxCommand := flag.NewFlagSet(conf.CLIsigntx, flag.PanicOnError)
+	signTxTx := signTxCommand.String(conf.CLItx, "", "Hex serialized transaction")
+	signTxAddress := signTxCommand.String(conf.CLIaddress, "", "Address of the wallet to sign with")
+	signTxSigHash := signTxCommand.String(conf.CLIsighash, conf.CLIdefaultsighash, "Parts of the transaction to sign: ALL, NONE or SINGLE, optionally followed by |ANYONECANPAY")
+	signTxPassphraseFile := signTxCommand.String(conf.CLIpassphrasefile, "", "File holding the wallet passphrase, instead of prompting for it")
+
+	sendRawTxCommand := flag.NewFlagSet(conf.CLIsendrawtx, flag.PanicOnError)
+	sendRawTxTx := sendRawTxCommand.String(conf.CLItx, "", "Hex serialized transaction")
+	sendRawTxNode := sendRawTxCommand.String(conf.CLInode, "", "Node to submit the transaction to instead of mining it locally")
+	sendRawTxMiner := sendRawTxCommand.String(conf.CLIminer, "", "Address to pay the reward to when mining locally")
 
 	newWalletCommand := flag.NewFlagSet(conf.CLInewwallet, flag.PanicOnError)
+	newWalletCurve := newWalletCommand.String(conf.CLIcurve, conf.WalletDefaultCurve, "Elliptic curve of the new key, p256 or secp256k1")
+	newWalletPassphraseFile := newWalletCommand.String(conf.CLIpassphrasefile, "", "File holding the wallet passphrase, instead of prompting for it")
+
+	restoreWalletCommand := flag.NewFlagSet(conf.CLIrestorewallet, flag.PanicOnError)
+	restoreWalletMnemonic := restoreWalletCommand.String(conf.CLImnemonic, "", "Mnemonic the wallet's keys are derived from")
+	restoreWalletPassphraseFile := restoreWalletCommand.String(conf.CLIpassphrasefile, "", "File holding the wallet passphrase, instead of prompting for it")
+
+	changePassphraseCommand := flag.NewFlagSet(conf.CLIchangepassphrase, flag.PanicOnError)
+	changePassphrasePassphraseFile := changePassphraseCommand.String(conf.CLIpassphrasefile, "", "File holding the current wallet passphrase, instead of prompting for it")
+	changePassphraseNewPassphraseFile := changePassphraseCommand.String(conf.CLInewpassphrasefile, "", "File holding the new wallet passphrase, instead of prompting for it")
 
 	printWalletsCommand := flag.NewFlagSet(conf.CLIprintwallets, flag.PanicOnError)
+	printWalletsPassphraseFile := printWalletsCommand.String(conf.CLIpassphrasefile, "", "File holding the wallet passphrase, instead of prompting for it")
+
+	startNodeCommand := flag.NewFlagSet(conf.CLIstartnode, flag.PanicOnError)
+	startNodeHost := startNodeCommand.String(conf.CLIhost, "localhost", "Host name peers reach the node at")
+	startNodePort := startNodeCommand.Int(conf.CLIport, 0, "Port to listen on (defaults to the network's port)")
+	startNodeMiner := startNodeCommand.String(conf.CLIminer, "", "Mining reward address")
+	startNodeSeeds := startNodeCommand.String(conf.CLIseeds, "", "Comma separated peers to connect to")
+
+	generateCommand := flag.NewFlagSet(conf.CLIgenerate, flag.PanicOnError)
+	generateBlocks := generateCommand.Int(conf.CLIblocks, 1, "Number of blocks to mine")
+	generateAddress := generateCommand.String(conf.CLIaddress, "", "Address to pay the rewards to")
 
-	switch os.Args[1] {
+	miningInfoCommand := flag.NewFlagSet(conf.CLImininginfo, flag.PanicOnError)
+	miningInfoNode := miningInfoCommand.String(conf.CLInode, "", "Node to ask for its mining info and stats instead of reading the local chain")
+	miningInfoBlocks := miningInfoCommand.Int(conf.CLIblocks, conf.POWhashrateblocks, "Number of recent blocks to estimate the network hashrate from")
+
+	printPendingCommand := flag.NewFlagSet(conf.CLIprintpendingtransactions, flag.PanicOnError)
+	printPendingNode := printPendingCommand.String(conf.CLInode, "", "Node to ask for its pooled transactions")
+
+	switch args[0] {
 	case conf.CLIcreateblockchain:
-		util.CheckAnxiety(createBlockchainCommand.Parse(os.Args[2:]))
+		util.CheckAnxiety(createBlockchainCommand.Parse(args[1:]))
 	case conf.CLIprintchain:
-		util.CheckAnxiety(printChainCommand.Parse(os.Args[2:]))
+		util.CheckAnxiety(printChainCommand.Parse(args[1:]))
+	case conf.CLIvalidatechain:
+		util.CheckAnxiety(validateChainCommand.Parse(args[1:]))
+	case conf.CLIreindex:
+		util.CheckAnxiety(reindexCommand.Parse(args[1:]))
+	case conf.CLIsupply:
+		util.CheckAnxiety(supplyCommand.Parse(args[1:]))
 	case conf.CLIgetbalance:
-		util.CheckAnxiety(getBalanceCommand.Parse(os.Args[2:]))
+		util.CheckAnxiety(getBalanceCommand.Parse(args[1:]))
 	case conf.CLIsend:
-		util.CheckAnxiety(sendCommand.Parse(os.Args[2:]))
+		util.CheckAnxiety(sendCommand.Parse(args[1:]))
+	case conf.CLIcreatemultisig:
+		util.CheckAnxiety(createMultiSigCommand.Parse(args[1:]))
+	case conf.CLIcreatemultisigtx:
+		util.CheckAnxiety(createMultiSigTxCommand.Parse(args[1:]))
+	case conf.CLIsigntx:
+		util.CheckAnxiety(signTxCommand.Parse(args[1:]))
+	case conf.CLIsendrawtx:
+		util.CheckAnxiety(sendRawTxCommand.Parse(args[1:]))
 	case conf.CLInewwallet:
-		util.CheckAnxiety(newWalletCommand.Parse(os.Args[2:]))
+		util.CheckAnxiety(newWalletCommand.Parse(args[1:]))
+	case conf.CLIrestorewallet:
+		util.CheckAnxiety(restoreWalletCommand.Parse(args[1:]))
+	case conf.CLIchangepassphrase:
+		util.CheckAnxiety(changePassphraseCommand.Parse(args[1:]))
 	case conf.CLIprintwallets:
-		util.CheckAnxiety(printWalletsCommand.Parse(os.Args[2:]))
+		util.CheckAnxiety(printWalletsCommand.Parse(args[1:]))
+	case conf.CLIstartnode:
+		util.CheckAnxiety(startNodeCommand.Parse(args[1:]))
+	case conf.CLIgenerate:
+		util.CheckAnxiety(generateCommand.Parse(args[1:]))
+	case conf.CLImininginfo:
+		util.CheckAnxiety(miningInfoCommand.Parse(args[1:]))
+	case conf.CLIprintpendingtransactions:
+		util.CheckAnxiety(printPendingCommand.Parse(args[1:]))
 	default:
 		failure()
 	}
 
 	if printChainCommand.Parsed() {
-		printChain()
+		printChain(*printChainStart, *printChainEnd)
+	}
+
+	if validateChainCommand.Parsed() {
+		validateChain()
+	}
+
+	if reindexCommand.Parsed() {
+		reindex()
+	}
+
+	if supplyCommand.Parsed() {
+		printSupply()
 	}
 
 	if createBlockchainCommand.Parsed() {
@@ -63,15 +180,64 @@ func Run() {
 	if sendCommand.Parsed() {
 		validateRequiredOption(*sendTo)
 		validateRequiredOption(*sendFrom)
-		send(*sendFrom, *sendTo, *sendAmount)
+		send(*sendFrom, *sendTo, *sendAmount, *sendFee, *sendNode, *sendPassphraseFile)
+	}
+
+	if createMultiSigCommand.Parsed() {
+		validateRequiredOption(*createMultiSigPubKeys)
+		createMultiSig(*createMultiSigRequired, *createMultiSigPubKeys, *createMultiSigPassphraseFile)
+	}
+
+	if createMultiSigTxCommand.Parsed() {
+		validateRequiredOption(*createMultiSigTxFrom)
+		validateRequiredOption(*createMultiSigTxTo)
+		createMultiSigTx(*createMultiSigTxFrom, *createMultiSigTxTo, *createMultiSigTxAmount, *createMultiSigTxFee, *createMultiSigTxPassphraseFile)
+	}
+
+	if signTxCommand.Parsed() {
+		validateRequiredOption(*signTxTx)
+		validateRequiredOption(*signTxAddress)
+		signTx(*signTxTx, *signTxAddress, *signTxSigHash, *signTxPassphraseFile)
+	}
+
+	if sendRawTxCommand.Parsed() {
+		validateRequiredOption(*sendRawTxTx)
+		sendRawTx(*sendRawTxTx, *sendRawTxNode, *sendRawTxMiner)
 	}
 
 	if newWalletCommand.Parsed() {
-		createNewWallet()
+		createNewWallet(*newWalletCurve, *newWalletPassphraseFile)
+	}
+
+	if restoreWalletCommand.Parsed() {
+		validateRequiredOption(*restoreWalletMnemonic)
+		restoreWallet(*restoreWalletMnemonic, *restoreWalletPassphraseFile)
+	}
+
+	if changePassphraseCommand.Parsed() {
+		changePassphrase(*changePassphrasePassphraseFile, *changePassphraseNewPassphraseFile)
 	}
 
 	if printWalletsCommand.Parsed() {
-		printWallets()
+		printWallets(*printWalletsPassphraseFile)
+	}
+
+	if startNodeCommand.Parsed() {
+		startNode(*startNodeHost, *startNodePort, *startNodeMiner, *startNodeSeeds)
+	}
+
+	if generateCommand.Parsed() {
+		validateRequiredOption(*generateAddress)
+		generate(*generateBlocks, *generateAddress)
+	}
+
+	if miningInfoCommand.Parsed() {
+		printMiningInfo(*miningInfoNode, *miningInfoBlocks)
+	}
+
+	if printPendingCommand.Parsed() {
+		validateRequiredOption(*printPendingNode)
+		printPendingTransactions(*printPendingNode)
 	}
 }
 
@@ -82,26 +248,111 @@ func validateRequiredOption(option string) {
 	}
 }
 
+// decodeTransaction decodes a hex serialized transaction given as an option, quitting if it isn't hex
+func decodeTransaction(rawTx string) *blockchain.Transaction {
+	data, err := hex.DecodeString(rawTx)
+	if err != nil {
+		fmt.Println("Invalid transaction hex.")
+		os.Exit(conf.CLIexitinvalid)
+	}
+	tx, err := blockchain.DeserializeTransaction(data)
+	if err != nil {
+		fmt.Printf("Invalid transaction: %v.\n", err)
+		os.Exit(conf.CLIexitinvalid)
+	}
+	return &tx
+}
+
+// openBlockchain opens the chain of the network in use, quitting if it can't
+func openBlockchain() *blockchain.Blockchain {
+	bc, err := blockchain.OpenBlockchain(settings)
+	exitOnError(err)
+	return bc
+}
+
+// exitOnError quits with the message and exit code for err, if there is an error
+func exitOnError(err error) {
+	if err != nil {
+		fmt.Println(errorMessage(err))
+		os.Exit(exitCode(err))
+	}
+}
+
+// errorMessage returns the sentence to print for err
+func errorMessage(err error) string {
+	switch {
+	case errors.Is(err, blockchain.ErrChainNotFound):
+		return "No existing Chroma chain.  Create DB first."
+	case errors.Is(err, blockchain.ErrChainExists):
+		return "Chroma chain already exists."
+	case errors.Is(err, blockchain.ErrChainInUse):
+		return "Chroma chain is in use by a running node. Stop the node first."
+	case errors.Is(err, wallet.ErrWrongPassphrase):
+		return "Wrong passphrase."
+	}
+	message := err.Error()
+	return strings.ToUpper(message[:1]) + message[1:] + "."
+}
+
+// exitCode returns the code to exit with for err, telling scripts what kind of error stopped the command
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, blockchain.ErrChainNotFound):
+		return conf.CLIexitnochain
+	case errors.Is(err, blockchain.ErrChainExists):
+		return conf.CLIexitchainexists
+	case errors.Is(err, blockchain.ErrChainInUse):
+		return conf.CLIexitinuse
+	case errors.Is(err, wallet.ErrWrongPassphrase), errors.Is(err, wallet.ErrWalletNotFound), errors.Is(err, wallet.ErrNoSeed):
+		return conf.CLIexitwallet
+	case errors.Is(err, blockchain.ErrInsufficientFunds):
+		return conf.CLIexitinsufficientfunds
+	case errors.Is(err, blockchain.ErrInvalidTransaction), errors.Is(err, blockchain.ErrInvalidBlock), errors.Is(err, blockchain.ErrTxNotFound),
+		errors.Is(err, wallet.ErrInvalidAddress):
+		return conf.CLIexitinvalid
+	case errors.Is(err, blockchain.ErrCorruptData), errors.Is(err, blockchain.ErrBlockNotFound), errors.Is(err, blockchain.ErrLegacyChain):
+		return conf.CLIexitcorrupt
+	}
+	return conf.CLIexitfailure
+}
+
 // printHelp prints CLI usage
 func printHelp() {
-	fmt.Println("Usage: ")
+	fmt.Println("Usage: chroma [-datadir {DIR}] [-network mainnet|testnet|regtest] [-conf {FILE}] COMMAND")
+	fmt.Println("  Settings not given are read from $CHROMA_DATADIR, $CHROMA_NETWORK and $CHROMA_CONF, then the config file")
+	fmt.Println("  (chroma.conf in the data directory, with datadir= and network= lines), defaulting to ~/.chroma on mainnet")
+	fmt.Println("  A chroma_db or wallet.dat in the working directory, where they were kept before, keeps it the default data directory")
+	fmt.Println("  Commands using the wallet prompt for its passphrase, or read it from the file given with -passphrase-file")
 	fmt.Println("  getbalance -address {ADDRESS} - Get balance of ADDRESS")
-	fmt.Println("  newwallet - Create a new CHROMA address")
-	fmt.Println("  printwallets - print all CHROMA addresses in the wallet")
+	fmt.Println("  newwallet [-curve p256|secp256k1] [-passphrase-file {FILE}] - Create a new CHROMA address derived from the wallet's seed, creating the seed first if needed")
+	fmt.Println("  restorewallet -mnemonic {MNEMONIC} [-passphrase-file {FILE}] - Restore the addresses derived from the seed of MNEMONIC that the chain shows were used")
+	fmt.Println("  printwallets [-passphrase-file {FILE}] - print all CHROMA addresses in the wallet with their balances and public keys")
+	fmt.Println("  changepassphrase [-passphrase-file {FILE}] [-new-passphrase-file {FILE}] - Re-encrypt the wallet file with a new passphrase")
+	fmt.Println("  createmultisig -required {M} -pubkeys {KEYS} [-passphrase-file {FILE}] - Create an address spendable with M signatures from the comma separated hex public KEYS")
 	fmt.Println("  createblockchain -address {ADDRESS} - Create a blockchain and send genesis block reward to ADDRESS")
-	fmt.Println("  printchain - Print all the blocks of the blockchain")
-	fmt.Println("  send -from {FROM} -to {TO} -amount {AMOUNT} - Send AMOUNT of coins from FROM address to TO")
+	fmt.Println("  printchain [-start {HEIGHT}] [-end {HEIGHT}] - Print the blocks of the blockchain, optionally only those from height START to END")
+	fmt.Println("  validatechain - Re-check every block and transaction from genesis and report the first invalid block")
+	fmt.Println("  reindex - Rebuild the UTXO set and the height and transaction indexes from the stored blocks")
+	fmt.Println("  supply - Compare the coins in circulation to the issuance schedule")
+	fmt.Println("  send -from {FROM} -to {TO} -amount {AMOUNT} [-fee {FEE}] [-node {NODE}] [-passphrase-file {FILE}] - Send AMOUNT of coins from FROM address to TO, paying FEE to the miner. With -node, pay from coins the node at NODE finds and submit to it instead of mining locally, which works while the node runs on this chain")
+	fmt.Println("  createmultisigtx -from {FROM} -to {TO} -amount {AMOUNT} [-fee {FEE}] [-passphrase-file {FILE}] - Print an unsigned transaction sending AMOUNT from the multisig address FROM to TO")
+	fmt.Println("  signtx -tx {TX} -address {ADDRESS} [-sighash {TYPE}] [-passphrase-file {FILE}] - Add the signatures of the wallet for ADDRESS to the hex transaction TX and print it. TYPE is ALL (default), NONE or SINGLE, optionally followed by |ANYONECANPAY")
+	fmt.Println("  sendrawtx -tx {TX} [-node {NODE}] [-miner {ADDRESS}] - Submit the hex transaction TX to the node at NODE, or mine it locally paying the reward to ADDRESS")
+	fmt.Println("  printpendingtransactions -node {NODE} - Print the transactions waiting to be mined by the node at NODE")
+	fmt.Println("  generate -address {ADDRESS} [-blocks {N}] - Mine N blocks (1 by default) paying their rewards to ADDRESS, on regtest only")
+	fmt.Println("  mininginfo [-blocks {N}] [-node {NODE}] - Print the difficulty and the network hashrate estimated from the last N blocks, or ask NODE for them along with its mining stats")
+	fmt.Println("  startnode [-port {PORT}] [-host {HOST}] [-miner {ADDRESS}] [-seeds {NODES}] - Run a node on PORT (the network's default if not given), syncing with NODES and mining rewards to ADDRESS")
 }
 
 // failure prints CLI usage and exits with an error
 func failure() {
 	printHelp()
-	os.Exit(1)
+	os.Exit(conf.CLIexitfailure)
 }
 
-// validateArgs ensures flag validity
-func validateArgs() {
-	if len(os.Args) < 2 {
+// validateArgs ensures a command is given after the settings
+func validateArgs(args []string) {
+	if len(args) < 1 {
 		failure()
 	}
 }
@@ -8,8 +8,9 @@ import (
 
 // createBlockchain creates a blockchain db
 func createBlockchain(address string) {
-	bc := blockchain.CreateBlockchain(address)
+	bc, err := blockchain.CreateBlockchain(settings, address)
+	exitOnError(err)
 	defer bc.DB.Close()
-	blockchain.ReindexUTXOs(bc)
+	exitOnError(blockchain.ReindexUTXOs(bc))
 	fmt.Println("CHROMA chain created")
 }
@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/casalettoj/chroma/blockchain"
+	conf "github.com/casalettoj/chroma/constants"
+)
+
+// createMultiSig creates an address spendable with required signatures from the given hex public keys
+// and stores its redeem script in the wallets file
+func createMultiSig(required int, pubKeys, passphraseFile string) {
+	var keys [][]byte
+	for _, pubKey := range strings.Split(pubKeys, ",") {
+		key, err := hex.DecodeString(strings.TrimSpace(pubKey))
+		if err != nil {
+			fmt.Printf("Invalid public key %s.\n", pubKey)
+			os.Exit(conf.CLIexitfailure)
+		}
+		keys = append(keys, key)
+	}
+
+	address, redeemScript, err := blockchain.NewMultiSigAddress(settings.Network, required, keys)
+	if err != nil {
+		fmt.Printf("Invalid multisig: %v.\n", err)
+		os.Exit(conf.CLIexitfailure)
+	}
+
+	wallets := openWallets(passphraseFile)
+	wallets.AddScript(address, redeemScript)
+	exitOnError(wallets.SaveWallets())
+	fmt.Printf("New %d of %d multisig address created. Address: %s\n", required, len(keys), address)
+	fmt.Printf("Redeem script: %s\n", redeemScript)
+}
@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"github.com/casalettoj/chroma/blockchain"
+	conf "github.com/casalettoj/chroma/constants"
+)
+
+// createMultiSigTx prints an unsigned transaction paying amount from a multisig address in the wallets file,
+// to be passed around its signers with signtx
+func createMultiSigTx(from, to string, amount, fee int, passphraseFile string) {
+	if amount <= 0 {
+		fmt.Println("Invalid amount.")
+		os.Exit(conf.CLIexitfailure)
+	}
+	if fee < 0 {
+		fmt.Println("Invalid fee.")
+		os.Exit(conf.CLIexitfailure)
+	}
+	redeemScript := openWallets(passphraseFile).GetScript(from)
+	if redeemScript == nil {
+		fmt.Printf("No multisig address %s in the wallet.\n", from)
+		os.Exit(conf.CLIexitwallet)
+	}
+
+	bc := openBlockchain()
+	defer bc.DB.Close()
+
+	newTx, err := blockchain.NewMultiSigTransaction(bc, redeemScript, to, amount, fee)
+	exitOnError(err)
+	fmt.Println(hex.EncodeToString(newTx.Serialize()))
+}
@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/casalettoj/chroma/blockchain"
+	conf "github.com/casalettoj/chroma/constants"
+	"github.com/casalettoj/chroma/wallet"
+)
+
+// generate mines the given number of blocks on regtest, paying their rewards to address
+func generate(blocks int, address string) {
+	if settings.Network.Name != conf.CFGregtest {
+		fmt.Printf("Blocks can only be generated on %s.\n", conf.CFGregtest)
+		os.Exit(conf.CLIexitfailure)
+	}
+	if blocks <= 0 {
+		fmt.Println("Invalid number of blocks.")
+		os.Exit(conf.CLIexitfailure)
+	}
+	if !wallet.ValidateAddress(settings.Network, address) {
+		fmt.Println("Invalid address.")
+		os.Exit(conf.CLIexitinvalid)
+	}
+
+	bc := openBlockchain()
+	defer bc.DB.Close()
+
+	generated, err := bc.Generate(blocks, address)
+	for _, block := range generated {
+		fmt.Printf("%x\n", block.Hash)
+	}
+	exitOnError(err)
+	stats := blockchain.DefaultMiner.Stats()
+	fmt.Printf("Generated %d blocks, the tip is at height %d.\n", len(generated), generated[len(generated)-1].Height)
+	fmt.Printf("Tried %d hashes in %v (%.0f hashes/s).\n", stats.Hashes, stats.Duration, stats.HashesPerSecond())
+}
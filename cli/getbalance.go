@@ -2,16 +2,14 @@ package cli
 
 import (
 	"fmt"
-
-	"github.com/casalettoj/chroma/blockchain"
 )
 
 // getBalance prints the balance of a given address to the console
 func getBalance(address string) {
-	total := 0
-	bc := blockchain.OpenBlockchain()
+	bc := openBlockchain()
 	defer bc.DB.Close()
-	bc.GetBalance(address)
+	total, err := bc.GetBalance(address)
+	exitOnError(err)
 
 	fmt.Printf("Balance of '%s': %d\n", address, total)
 }
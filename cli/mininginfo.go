@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/casalettoj/chroma/blockchain"
+	"github.com/casalettoj/chroma/network"
+)
+
+// printMiningInfo prints the difficulty and estimated network hashrate of the chain, estimated over the given
+// number of recent blocks. With a node, they come from the node instead, along with its mining stats.
+func printMiningInfo(node string, blocks int) {
+	var info blockchain.MiningInfo
+	var err error
+	if node != "" {
+		info, err = network.GetMiningInfo(node, blocks)
+		exitOnError(err)
+	} else {
+		bc := openBlockchain()
+		info, err = bc.GetMiningInfo(blocks)
+		bc.DB.Close()
+		exitOnError(err)
+	}
+
+	fmt.Printf("Height: %d\n", info.Height)
+	fmt.Printf("Next block bits: %08x (difficulty %.2f)\n", info.Bits, info.Difficulty)
+	fmt.Printf("Network hashrate: %.0f hashes/s over the last %d blocks\n", info.NetworkHashrate, blocks)
+	if node == "" {
+		return
+	}
+	if info.Miner == nil {
+		fmt.Printf("%s isn't mining\n", node)
+		return
+	}
+	stats := info.Miner
+	fmt.Printf("Blocks mined: %d\n", stats.Blocks)
+	fmt.Printf("Hashrate: %.0f hashes/s\n", stats.HashesPerSecond())
+	fmt.Printf("Hashes tried: %d in %v\n", stats.Hashes, stats.Duration)
+	fmt.Printf("Attempts per block: %.0f\n", stats.AttemptsPerBlock())
+	fmt.Printf("Time to block: %v\n", stats.TimeToBlock())
+	if stats.Blocks > 0 {
+		fmt.Printf("Last block: %d attempts in %v\n", stats.LastAttempts, stats.LastDuration)
+	}
+}
@@ -2,14 +2,28 @@ package cli
 
 import (
 	"fmt"
+	"os"
 
+	conf "github.com/casalettoj/chroma/constants"
 	"github.com/casalettoj/chroma/wallet"
 )
 
-// createNewWallet creates a new private/public key pair and adds it to the wallets file.
-func createNewWallet() {
-	wallets := wallet.OpenWallets()
-	address := wallets.AddNewWallet()
-	wallets.SaveWallets()
+// createNewWallet derives the next private/public key pair on the named curve from the seed and adds it to the
+// wallets file, creating a seed first if there isn't one.
+func createNewWallet(curveName, passphraseFile string) {
+	curve, err := wallet.ParseKeyCurve(curveName)
+	if err != nil {
+		fmt.Printf("Invalid curve: %v.\n", err)
+		os.Exit(conf.CLIexitfailure)
+	}
+	wallets := openWallets(passphraseFile)
+	if !wallets.HasSeed() {
+		mnemonic := wallets.NewSeed()
+		fmt.Println("New seed created. Write down this mnemonic, it restores every address derived from the seed:")
+		fmt.Println(mnemonic)
+	}
+	address, err := wallets.AddNewWallet(curve)
+	exitOnError(err)
+	exitOnError(wallets.SaveWallets())
 	fmt.Printf("New wallet created. Address: %s\n", address)
 }
@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	conf "github.com/casalettoj/chroma/constants"
+	"github.com/casalettoj/chroma/wallet"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// openWallets opens the wallets file with the passphrase in passphraseFile, prompting for it if no file is given.
+// A new or plaintext wallet file gets encrypted with the passphrase, so the prompt asks for it twice.
+func openWallets(passphraseFile string) *wallet.Wallets {
+	encrypted := wallet.IsWalletFileEncrypted(settings)
+	if !encrypted && passphraseFile == "" {
+		fmt.Println("Choose a passphrase to encrypt the wallet file with.")
+	}
+	passphrase := readPassphrase(passphraseFile, "Wallet passphrase: ", !encrypted)
+	wallets, err := wallet.OpenWallets(settings, passphrase)
+	exitOnError(err)
+	return wallets
+}
+
+// readPassphrase returns the passphrase in passphraseFile, or prompts for it if no file is given.
+// With confirm the prompt asks for it twice, for a new passphrase.
+func readPassphrase(passphraseFile, prompt string, confirm bool) string {
+	var passphrase string
+	if passphraseFile != "" {
+		content, err := ioutil.ReadFile(passphraseFile)
+		exitOnError(err)
+		passphrase = strings.TrimRight(string(content), "\r\n")
+	} else {
+		passphrase = promptPassphrase(prompt)
+		if confirm && promptPassphrase("Repeat "+strings.ToLower(prompt)) != passphrase {
+			fmt.Println("Passphrases don't match.")
+			os.Exit(conf.CLIexitwallet)
+		}
+	}
+	if passphrase == "" {
+		fmt.Println("Passphrase can't be empty.")
+		os.Exit(conf.CLIexitwallet)
+	}
+	return passphrase
+}
+
+// promptPassphrase prompts for a passphrase on the terminal without echoing it
+func promptPassphrase(prompt string) string {
+	stdin := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(stdin) {
+		fmt.Printf("No terminal to prompt for the passphrase on, use -%s.\n", conf.CLIpassphrasefile)
+		os.Exit(conf.CLIexitwallet)
+	}
+	fmt.Print(prompt)
+	passphrase, err := terminal.ReadPassword(stdin)
+	fmt.Println()
+	exitOnError(err)
+	return string(passphrase)
+}
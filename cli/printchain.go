@@ -2,23 +2,32 @@ package cli
 
 import (
 	"fmt"
+	"os"
 
-	"github.com/casalettoj/chroma/blockchain"
+	conf "github.com/casalettoj/chroma/constants"
 )
 
-// printChain iterates through the chain and prints the data of each
-func printChain() {
-	bc := blockchain.OpenBlockchain()
+// printChain prints the data of each block from height end (the tip if negative) back to height start
+func printChain(start, end int) {
+	bc := openBlockchain()
 	defer bc.DB.Close()
-	bci := bc.Iterator()
 
-	for {
+	best, err := bc.GetBestHeight()
+	exitOnError(err)
+	if end < 0 || end > best {
+		end = best
+	}
+	if start < 0 || start > end {
+		fmt.Println("Invalid height range.")
+		bc.DB.Close()
+		os.Exit(conf.CLIexitfailure)
+	}
+
+	for height := end; height >= start; height-- {
+		block, err := bc.GetBlockByHeight(height)
+		exitOnError(err)
 		fmt.Println()
-		block := bci.Next()
-		fmt.Println(block)
+		fmt.Println(&block)
 		fmt.Println()
-		if bci.IsGenesisBlock() {
-			break
-		}
 	}
 }
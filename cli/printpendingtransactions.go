@@ -0,0 +1,17 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/casalettoj/chroma/network"
+)
+
+// printPendingTransactions prints every transaction pooled by a running node
+func printPendingTransactions(node string) {
+	txs, err := network.GetPendingTransactions(node)
+	exitOnError(err)
+	fmt.Printf("%d pending transactions at %s\n", len(txs), node)
+	for _, tx := range txs {
+		fmt.Println(&tx)
+	}
+}
@@ -4,16 +4,28 @@ import (
 	"fmt"
 
 	"github.com/casalettoj/chroma/blockchain"
-	"github.com/casalettoj/chroma/wallet"
 )
 
-// printWallets prints the address of every wallet in the wallet file.
-func printWallets() {
-	bc := blockchain.OpenBlockchain()
-	wallets := wallet.OpenWallets()
+// printWallets prints the address, balance, curve and public key of every wallet in the wallet file,
+// then the address and balance of every multisig address in it.
+func printWallets(passphraseFile string) {
+	bc := openBlockchain()
+	defer bc.DB.Close()
+	wallets := openWallets(passphraseFile)
 	fmt.Println("Wallet Addresses:")
-	for address := range wallets.Wallets {
-		balance := bc.GetBalance(address)
-		fmt.Printf("%s %d\n", address, balance)
+	for address, w := range wallets.Wallets {
+		balance, err := bc.GetBalance(address)
+		exitOnError(err)
+		fmt.Printf("%s %d %s %x\n", address, balance, w.Curve, w.PublicKey)
+	}
+	if len(wallets.Scripts) == 0 {
+		return
+	}
+	fmt.Println("Multisig Addresses:")
+	for address, script := range wallets.Scripts {
+		balance, err := bc.GetBalance(address)
+		exitOnError(err)
+		required, pubKeys, _ := blockchain.ParseMultiSigScript(script)
+		fmt.Printf("%s %d (%d of %d)\n", address, balance, required, len(pubKeys))
 	}
 }
@@ -0,0 +1,15 @@
+package cli
+
+import (
+	"fmt"
+)
+
+// reindex rebuilds the UTXO set and indexes from the blocks of the main chain
+func reindex() {
+	bc := openBlockchain()
+	defer bc.DB.Close()
+	exitOnError(bc.Reindex())
+	height, err := bc.GetBestHeight()
+	exitOnError(err)
+	fmt.Printf("CHROMA chain reindexed up to height %d\n", height)
+}
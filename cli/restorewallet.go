@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	conf "github.com/casalettoj/chroma/constants"
+	"github.com/casalettoj/chroma/wallet"
+)
+
+// restoreWallet rebuilds the wallets derived from a mnemonic, keeping every address the chain shows was used
+func restoreWallet(mnemonic, passphraseFile string) {
+	wallets := openWallets(passphraseFile)
+	if wallets.HasSeed() {
+		fmt.Printf("The wallet already has a seed. Move %s aside to restore into a new one.\n", settings.WalletFile())
+		os.Exit(conf.CLIexitwallet)
+	}
+
+	bc := openBlockchain()
+	defer bc.DB.Close()
+
+	usedPubKeyHashes, err := bc.GetUsedPubKeyHashes()
+	exitOnError(err)
+	restored, err := wallets.Restore(mnemonic, func(address string) bool {
+		pubKeyHash, _, err := wallet.DecodeAddress(settings.Network, address)
+		return err == nil && usedPubKeyHashes[hex.EncodeToString(pubKeyHash)]
+	})
+	if err != nil {
+		fmt.Printf("Invalid mnemonic: %v.\n", err)
+		os.Exit(conf.CLIexitwallet)
+	}
+	exitOnError(wallets.SaveWallets())
+	fmt.Printf("Restored %d addresses.\n", restored)
+}
@@ -1,29 +1,70 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
 	"github.com/casalettoj/chroma/blockchain"
+	conf "github.com/casalettoj/chroma/constants"
+	"github.com/casalettoj/chroma/network"
 	"github.com/casalettoj/chroma/wallet"
 )
 
-// send creates a TX and CoinbaseTX and mines a new transaction
-func send(from, to string, amount int) {
+// send creates a TX and either submits it to a node or mines it locally alongside a CoinbaseTX.
+// With a node the outputs to pay from come from the node, so the local chain isn't opened and the node can keep
+// running on it.
+func send(from, to string, amount, fee int, node, passphraseFile string) {
 	if amount <= 0 {
 		fmt.Println("Invalid amount.")
-		os.Exit(1)
+		os.Exit(conf.CLIexitfailure)
+	}
+	if fee < 0 {
+		fmt.Println("Invalid fee.")
+		os.Exit(conf.CLIexitfailure)
+	}
+
+	wallets := openWallets(passphraseFile)
+	if node != "" {
+		sendViaNode(wallets, from, to, amount, fee, node)
+		return
 	}
 
-	bc := blockchain.OpenBlockchain()
+	bc := openBlockchain()
 	defer bc.DB.Close()
 
-	wallets := wallet.OpenWallets()
+	newTx, err := blockchain.NewTransaction(bc, wallets, to, from, amount, fee)
+	exitOnError(err)
 
-	newTx := blockchain.NewTransaction(bc, wallets, to, from, amount)
-	coinbaseTx := blockchain.NewCoinbaseTx(from, "")
+	height, err := bc.GetBestHeight()
+	exitOnError(err)
+	coinbaseTx, err := blockchain.NewCoinbaseTx(settings.Network, from, "", height+1, fee)
+	exitOnError(err)
 	Txs := []*blockchain.Transaction{coinbaseTx, newTx}
-	newBlock := bc.MineBlock(Txs)
-	blockchain.UpdateUTXOs(bc, newBlock)
+	_, err = bc.MineBlock(Txs)
+	exitOnError(err)
 	fmt.Printf("Sent %d to %s.\n", amount, to)
 }
+
+// sendViaNode pays from outputs the node finds and submits the TX to it, reporting whether the node accepted it
+func sendViaNode(wallets *wallet.Wallets, from, to string, amount, fee int, node string) {
+	fromWallet, err := wallets.GetWallet(from)
+	exitOnError(err)
+	coins, err := network.GetCoins(node, wallet.HashPublicKey(fromWallet.PublicKey), amount+fee)
+	if err != nil {
+		fmt.Printf("Failed getting coins from node %s: %v.\n", node, err)
+		os.Exit(conf.CLIexitfailure)
+	}
+	newTx, err := blockchain.NewPaymentFromCoins(settings.Network, &fromWallet, coins, to, amount, fee)
+	exitOnError(err)
+
+	err = network.SendTransaction(node, newTx)
+	if errors.Is(err, blockchain.ErrInvalidTransaction) {
+		exitOnError(err)
+	}
+	if err != nil {
+		fmt.Printf("Failed sending to node %s: %v.\n", node, err)
+		os.Exit(conf.CLIexitfailure)
+	}
+	fmt.Printf("Sent %d to %s via node %s in transaction %x.\n", amount, to, node, newTx.ID)
+}
@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/casalettoj/chroma/blockchain"
+	conf "github.com/casalettoj/chroma/constants"
+	"github.com/casalettoj/chroma/network"
+	"github.com/casalettoj/chroma/wallet"
+)
+
+// sendRawTx submits a hex serialized transaction to a node, or mines it locally paying its fee to minerAddress
+func sendRawTx(rawTx, node, minerAddress string) {
+	tx := decodeTransaction(rawTx)
+	if node != "" {
+		err := network.SendTransaction(node, tx)
+		if errors.Is(err, blockchain.ErrInvalidTransaction) {
+			exitOnError(err)
+		}
+		if err != nil {
+			fmt.Printf("Failed sending to node %s: %v.\n", node, err)
+			os.Exit(conf.CLIexitfailure)
+		}
+		fmt.Printf("Sent transaction %x via node %s.\n", tx.ID, node)
+		return
+	}
+	if !wallet.ValidateAddress(settings.Network, minerAddress) {
+		fmt.Println("Invalid miner address.")
+		os.Exit(conf.CLIexitinvalid)
+	}
+
+	bc := openBlockchain()
+	defer bc.DB.Close()
+
+	// Pooling the transaction checks it the way a node would and works out its fee
+	pool := blockchain.NewMempool()
+	if err := pool.Add(bc, tx); err != nil {
+		fmt.Printf("Invalid transaction: %v.\n", err)
+		os.Exit(conf.CLIexitinvalid)
+	}
+	Txs, fees, err := pool.SelectForBlock(bc, 1)
+	exitOnError(err)
+	height, err := bc.GetBestHeight()
+	exitOnError(err)
+	coinbaseTx, err := blockchain.NewCoinbaseTx(settings.Network, minerAddress, "", height+1, fees)
+	exitOnError(err)
+	_, err = bc.MineBlock(append([]*blockchain.Transaction{coinbaseTx}, Txs...))
+	exitOnError(err)
+	fmt.Printf("Mined transaction %x.\n", tx.ID)
+}
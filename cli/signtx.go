@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"github.com/casalettoj/chroma/blockchain"
+	conf "github.com/casalettoj/chroma/constants"
+)
+
+// signTx adds the signatures of the named sighash type the wallet at address can make to a hex serialized transaction,
+// then prints the transaction and how many signatures each multisig input still needs
+func signTx(rawTx, address, sigHashName, passphraseFile string) {
+	hashType, err := blockchain.ParseSigHashType(sigHashName)
+	if err != nil {
+		fmt.Printf("Invalid sighash type: %v.\n", err)
+		os.Exit(conf.CLIexitfailure)
+	}
+	tx := decodeTransaction(rawTx)
+	wallets := openWallets(passphraseFile)
+	signer, err := wallets.GetWallet(address)
+	exitOnError(err)
+
+	bc := openBlockchain()
+	defer bc.DB.Close()
+
+	signed, err := bc.SignTransaction(tx, &signer, hashType)
+	exitOnError(err)
+	fmt.Printf("Signed %d of %d inputs.\n", signed, len(tx.Vin))
+	for i := range tx.Vin {
+		if signatures, required, ok := tx.MultiSigProgress(i); ok {
+			fmt.Printf("Input %d: %d of %d signatures\n", i, signatures, required)
+		}
+	}
+	if bc.VerifyTransaction(tx) {
+		fmt.Println("Transaction is fully signed.")
+	} else {
+		fmt.Println("Transaction needs more signatures.")
+	}
+	fmt.Println(hex.EncodeToString(tx.Serialize()))
+}
@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	conf "github.com/casalettoj/chroma/constants"
+	"github.com/casalettoj/chroma/network"
+	"github.com/casalettoj/chroma/wallet"
+)
+
+// startNode runs a network node on the given port (the network's default if 0) until interrupted,
+// mining to minerAddress if one is given
+func startNode(host string, port int, minerAddress, seeds string) {
+	if port == 0 {
+		port = settings.Network.DefaultPort
+	}
+	if port < 0 {
+		fmt.Println("Invalid port.")
+		os.Exit(conf.CLIexitfailure)
+	}
+	if minerAddress != "" && !wallet.ValidateAddress(settings.Network, minerAddress) {
+		fmt.Println("Invalid miner address.")
+		os.Exit(conf.CLIexitinvalid)
+	}
+
+	var seedNodes []string
+	for _, seed := range strings.Split(seeds, ",") {
+		if seed = strings.TrimSpace(seed); seed != "" {
+			seedNodes = append(seedNodes, seed)
+		}
+	}
+
+	bc := openBlockchain()
+	defer bc.DB.Close()
+
+	server := network.NewServer(fmt.Sprintf("%s:%d", host, port), bc, seedNodes)
+	server.MinerAddress = minerAddress
+	exitOnError(server.Start())
+	fmt.Printf("CHROMA node listening on %s\n", server.Address)
+	if minerAddress != "" {
+		fmt.Printf("Mining rewards go to %s\n", minerAddress)
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	<-interrupt
+	exitOnError(server.Close())
+	fmt.Println("CHROMA node stopped")
+}
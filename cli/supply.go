@@ -0,0 +1,25 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/casalettoj/chroma/blockchain"
+)
+
+// printSupply prints the coins held in the UTXO set next to what the subsidy schedule allows at the tip
+func printSupply() {
+	bc := openBlockchain()
+	defer bc.DB.Close()
+
+	height, err := bc.GetBestHeight()
+	exitOnError(err)
+	supply, err := blockchain.GetCirculatingSupply(bc)
+	exitOnError(err)
+	interval := settings.Network.HalvingInterval
+	nextHalving := (height/interval + 1) * interval
+	fmt.Printf("Height: %d\n", height)
+	fmt.Printf("Circulating supply: %d\n", supply)
+	fmt.Printf("Scheduled supply: %d\n", blockchain.ScheduledSupply(settings.Network, height))
+	fmt.Printf("Maximum supply: %d\n", blockchain.MaxSupply(settings.Network))
+	fmt.Printf("Next block subsidy: %d (halves at height %d)\n", blockchain.GetBlockSubsidy(settings.Network, height+1), nextHalving)
+}
@@ -0,0 +1,23 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	conf "github.com/casalettoj/chroma/constants"
+)
+
+// validateChain re-checks every block and transaction from genesis and reports the first invalid block
+func validateChain() {
+	bc := openBlockchain()
+	defer bc.DB.Close()
+
+	if err := bc.Validate(); err != nil {
+		fmt.Println(err)
+		bc.DB.Close()
+		os.Exit(conf.CLIexitinvalid)
+	}
+	height, err := bc.GetBestHeight()
+	exitOnError(err)
+	fmt.Printf("CHROMA chain is valid up to height %d\n", height)
+}
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	conf "github.com/casalettoj/chroma/constants"
+)
+
+// Config says where CHROMA keeps its files and which network it runs on
+type Config struct {
+	// DataDir holds the chain and wallet of every network, mainnet's at its top and the others' in a directory
+	// named after the network
+	DataDir string
+	Network *Network
+}
+
+// Dir returns the directory the network's files are kept in
+func (c *Config) Dir() string {
+	if c.Network.Name == conf.CFGmainnet {
+		return c.DataDir
+	}
+	return filepath.Join(c.DataDir, c.Network.Name)
+}
+
+// DBFile returns the path of the network's chain database
+func (c *Config) DBFile() string {
+	return filepath.Join(c.Dir(), conf.DBdbfile)
+}
+
+// WalletFile returns the path of the network's wallet file
+func (c *Config) WalletFile() string {
+	return filepath.Join(c.Dir(), conf.WalletFile)
+}
+
+// MakeDir creates the network's directory if it doesn't exist yet, readable only by the user
+func (c *Config) MakeDir() error {
+	return os.MkdirAll(c.Dir(), 0700)
+}
+
+// New returns the config for the named network with the given data directory
+func New(dataDir, network string) (*Config, error) {
+	n, err := GetNetwork(network)
+	if err != nil {
+		return nil, err
+	}
+	return &Config{DataDir: dataDir, Network: n}, nil
+}
+
+// Load builds the config from the options at the start of args, which come before the command, returning the rest.
+// Each setting is taken from the first of: the -datadir and -network options, the CHROMA_DATADIR and CHROMA_NETWORK
+// environment variables, the config file (-conf, CHROMA_CONF or chroma.conf in the data directory) and the defaults:
+// ~/.chroma on mainnet, or the working directory if it holds a chain or wallet from before data directories.
+func Load(args []string) (*Config, []string, error) {
+	options := flag.NewFlagSet("chroma", flag.ContinueOnError)
+	options.SetOutput(ioutil.Discard)
+	dataDirOption := options.String(conf.CFGdatadir, "", "Directory the chain and wallet are kept in")
+	networkOption := options.String(conf.CFGnetwork, "", "Network to run on")
+	fileOption := options.String(conf.CFGconf, "", "Config file to read settings from")
+	if err := options.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	dataDir := firstSet(*dataDirOption, os.Getenv(conf.ENVdatadir))
+	network := firstSet(*networkOption, os.Getenv(conf.ENVnetwork))
+	file := firstSet(*fileOption, os.Getenv(conf.ENVconf))
+	// Only a config file that was asked for has to exist
+	required := file != ""
+	if !required {
+		file = filepath.Join(firstSet(dataDir, defaultDataDir()), conf.CFGfile)
+	}
+	settings, err := readConfigFile(file)
+	if os.IsNotExist(err) && !required {
+		err = nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	dataDir = firstSet(dataDir, settings[conf.CFGdatadir], defaultDataDir())
+	network = firstSet(network, settings[conf.CFGnetwork], conf.CFGmainnet)
+
+	config, err := New(dataDir, network)
+	if err != nil {
+		return nil, nil, err
+	}
+	return config, options.Args(), nil
+}
+
+// firstSet returns the first of values that isn't empty
+func firstSet(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// defaultDataDir returns ~/.chroma, or .chroma in the working directory if there's no home directory.
+// Chains and wallets used to be kept in the working directory, so it stays the default while it holds either.
+func defaultDataDir() string {
+	for _, file := range []string{conf.DBdbfile, conf.WalletFile} {
+		if _, err := os.Stat(file); err == nil {
+			return "."
+		}
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return conf.CFGdefaultdatadir
+	}
+	return filepath.Join(home, conf.CFGdefaultdatadir)
+}
+
+// readConfigFile reads the key=value lines of a config file, skipping blank lines and # comments
+func readConfigFile(file string) (map[string]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	settings := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		parts := strings.SplitN(text, "=", 2)
+		key := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || (key != conf.CFGdatadir && key != conf.CFGnetwork) {
+			return nil, fmt.Errorf("%s line %d: expected %s=... or %s=...", file, line, conf.CFGdatadir, conf.CFGnetwork)
+		}
+		settings[key] = strings.TrimSpace(parts[1])
+	}
+	return settings, scanner.Err()
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"fmt"
+	"math"
+
+	conf "github.com/casalettoj/chroma/constants"
+)
+
+// Network holds the parameters that set one CHROMA chain apart from another. Nodes and wallets of different
+// networks can't be mixed: the genesis blocks differ, addresses carry different version bytes and peers
+// on another network are ignored.
+type Network struct {
+	Name string
+	// GenesisMessage is written into the coinbase of the genesis block
+	GenesisMessage string
+	// AddressVersion and ScriptHashVersion are the version bytes of addresses paying to public key and script hashes
+	AddressVersion    byte
+	ScriptHashVersion byte
+	// TargetBits is the number of leading zero bits the genesis block's hash needs, and the easiest difficulty
+	// retargeting can reach
+	TargetBits int
+	// RetargetInterval is the number of blocks between difficulty adjustments, 0 for a difficulty that never changes
+	RetargetInterval int
+	// InitialSubsidy is the number of coins a coinbase tx may create before the first halving, and HalvingInterval
+	// the number of blocks between each halving. Together they cap the supply.
+	InitialSubsidy  int
+	HalvingInterval int
+	// CoinbaseMaturity is the number of blocks that must be mined on top of a coinbase tx before its outputs can be spent
+	CoinbaseMaturity int
+	// DefaultPort is the port a node listens on unless another is given
+	DefaultPort int
+}
+
+// Mainnet is the main CHROMA network, whose data lives at the top of the data directory
+var Mainnet = Network{
+	Name:              conf.CFGmainnet,
+	GenesisMessage:    conf.Message,
+	AddressVersion:    conf.Version,
+	ScriptHashVersion: conf.ScriptHashVersion,
+	TargetBits:        conf.POWinitialtargetbits,
+	RetargetInterval:  conf.POWretargetinterval,
+	InitialSubsidy:    conf.TXcoinbaseaward,
+	HalvingInterval:   conf.TXhalvinginterval,
+	CoinbaseMaturity:  conf.TXcoinbasematurity,
+	DefaultPort:       conf.CFGmainnetport,
+}
+
+// Testnet is a network for trying things out with coins that are worth nothing, at a lower difficulty
+var Testnet = Network{
+	Name:              conf.CFGtestnet,
+	GenesisMessage:    "CHROMA testnet",
+	AddressVersion:    conf.TestVersion,
+	ScriptHashVersion: conf.TestScriptHashVersion,
+	TargetBits:        conf.POWtestnettargetbits,
+	RetargetInterval:  conf.POWretargetinterval,
+	InitialSubsidy:    conf.TXcoinbaseaward,
+	HalvingInterval:   conf.TXhalvinginterval,
+	CoinbaseMaturity:  conf.TXcoinbasematurity,
+	DefaultPort:       conf.CFGtestnetport,
+}
+
+// Regtest is a local network for tests whose difficulty is so low blocks mine instantly.
+// It never retargets, so generating blocks faster than POWtargetblocktime doesn't make them any harder.
+var Regtest = Network{
+	Name:              conf.CFGregtest,
+	GenesisMessage:    "CHROMA regtest",
+	AddressVersion:    conf.TestVersion,
+	ScriptHashVersion: conf.TestScriptHashVersion,
+	TargetBits:        conf.POWregtesttargetbits,
+	InitialSubsidy:    conf.TXcoinbaseaward,
+	HalvingInterval:   conf.TXregtesthalvinginterval,
+	CoinbaseMaturity:  conf.TXcoinbasematurity,
+	DefaultPort:       conf.CFGregtestport,
+}
+
+// Validate returns an error if the network's consensus parameters can't work: a retarget window is timed from its
+// first block to its last, so it needs at least 2, the genesis target has to fit in a 256 bit hash and the supply,
+// under twice InitialSubsidy*HalvingInterval, has to fit in an int64
+func (n *Network) Validate() error {
+	if n.RetargetInterval < 0 || n.RetargetInterval == 1 {
+		return fmt.Errorf("network %s retargets every %d blocks, must be 0 for never or at least 2", n.Name, n.RetargetInterval)
+	}
+	if n.TargetBits < 1 || n.TargetBits > 255 {
+		return fmt.Errorf("network %s needs %d leading zero bits, must be from 1 to 255", n.Name, n.TargetBits)
+	}
+	if n.CoinbaseMaturity < 0 {
+		return fmt.Errorf("network %s matures coinbase txs after %d blocks, can't be negative", n.Name, n.CoinbaseMaturity)
+	}
+	if n.InitialSubsidy < 1 || n.HalvingInterval < 1 {
+		return fmt.Errorf("network %s has a subsidy of %d halving every %d blocks, both must be at least 1", n.Name, n.InitialSubsidy, n.HalvingInterval)
+	}
+	if int64(n.InitialSubsidy) > math.MaxInt64/2/int64(n.HalvingInterval) {
+		return fmt.Errorf("network %s has a subsidy of %d halving every %d blocks, a supply too large to count", n.Name, n.InitialSubsidy, n.HalvingInterval)
+	}
+	return nil
+}
+
+// GetNetwork returns the network with the given name
+func GetNetwork(name string) (*Network, error) {
+	for _, network := range []*Network{&Mainnet, &Testnet, &Regtest} {
+		if network.Name == name {
+			return network, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown network %q, must be %s, %s or %s", name, conf.CFGmainnet, conf.CFGtestnet, conf.CFGregtest)
+}
@@ -0,0 +1,38 @@
+package config
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	for _, network := range []*Network{&Mainnet, &Testnet, &Regtest} {
+		if err := network.Validate(); err != nil {
+			t.Errorf("%s: %v", network.Name, err)
+		}
+	}
+
+	for _, interval := range []int{-1, 1} {
+		network := Mainnet
+		network.RetargetInterval = interval
+		if network.Validate() == nil {
+			t.Errorf("retarget interval %d was accepted", interval)
+		}
+	}
+	for _, bits := range []int{0, 256} {
+		network := Mainnet
+		network.TargetBits = bits
+		if network.Validate() == nil {
+			t.Errorf("%d target bits were accepted", bits)
+		}
+	}
+	network := Mainnet
+	network.CoinbaseMaturity = -1
+	if network.Validate() == nil {
+		t.Error("a negative coinbase maturity was accepted")
+	}
+	for _, schedule := range [][2]int{{0, 100}, {100, 0}, {1 << 40, 1 << 30}} {
+		network := Mainnet
+		network.InitialSubsidy, network.HalvingInterval = schedule[0], schedule[1]
+		if network.Validate() == nil {
+			t.Errorf("a subsidy of %d halving every %d blocks was accepted", schedule[0], schedule[1])
+		}
+	}
+}
@@ -3,7 +3,7 @@ package constants
 const (
 	// Message memes something
 	Message = "09 F9 11 02 9D 74 E3 5B D8 41 56 C5 63 56 88 C0"
-	// DBdbfile is the database filename
+	// DBdbfile is the filename of the database in a network's data directory
 	DBdbfile = "chroma_db"
 	// DBblocksbucket is the name of the bolt bucket the blocks are stored in, keyed by hash.
 	DBblocksbucket = "blocks"
@@ -11,11 +11,62 @@ const (
 	DBtxbucket = "transactions"
 	//DButxobucket is the name of the bolt bucket UTXOs are stored in, keyed by TXID
 	DButxobucket = "utxoset"
+	// DBworkbucket is the name of the bolt bucket the cumulative work of the chain up to each block is stored in, keyed by hash
+	DBworkbucket = "chainwork"
+	// DBundobucket is the name of the bolt bucket holding the outputs each block spent, keyed by hash
+	DBundobucket = "undo"
+	// DBheightsbucket is the name of the bolt bucket mapping each height of the main chain to its block's hash
+	DBheightsbucket = "heights"
 	// DBlasthash is the key the hash of the tip of the chain is stored in
 	DBlasthash = "lasthash"
+	// DBopentimeout is the number of seconds to wait for another process to let go of the database before giving up
+	DBopentimeout = 1
 
-	// TXcoinbaseaward is the amount of coins awarded for mining a block
+	// TXcoinbaseaward is the amount of coins awarded for mining a block before the first halving
 	TXcoinbaseaward = 1000
+	// TXhalvinginterval is the number of blocks between each halving of the coinbase award
+	TXhalvinginterval = 2000
+	// TXregtesthalvinginterval is TXhalvinginterval on regtest, short enough that tests can reach a halving
+	TXregtesthalvinginterval = 150
+	// TXcoinbasematurity is the number of blocks that must be mined on top of a coinbase tx before its outputs can be spent
+	TXcoinbasematurity = 10
+	// TXblockmaxtxs is the most pooled transactions a miner puts in a single block
+	TXblockmaxtxs = 100
+
+	// POWinitialtargetbits is the number of leading zero bits the genesis block's hash needs
+	POWinitialtargetbits = 18
+	// POWtestnettargetbits is POWinitialtargetbits on testnet
+	POWtestnettargetbits = 14
+	// POWregtesttargetbits is POWinitialtargetbits on regtest, low enough that blocks mine instantly
+	POWregtesttargetbits = 1
+	// POWretargetinterval is the number of blocks between difficulty adjustments
+	POWretargetinterval = 10
+	// POWtargetblocktime is the number of seconds difficulty adjustments aim for between blocks
+	POWtargetblocktime = 30
+	// POWmaxnonce is the last nonce tried before the miner changes the block's timestamp or extra nonce and starts over
+	POWmaxnonce = 1<<32 - 1
+	// POWnoncebatch is the number of nonces a mining goroutine claims at a time
+	POWnoncebatch = 1 << 12
+	// POWhashrateseconds is the number of seconds between hashrate reports while mining
+	POWhashrateseconds = 1
+	// POWhashrateblocks is the number of recent blocks the network hashrate is estimated from unless told otherwise
+	POWhashrateblocks = 30
+
+	// BLOCKmediantimespan is the number of previous blocks whose median timestamp a new block can't be older than
+	BLOCKmediantimespan = 11
+	// BLOCKmaxfutureseconds is how far past the current time a block's timestamp may be
+	BLOCKmaxfutureseconds = 2 * 60 * 60
+
+	// SCRIPTmaxsize is the longest a script can be in bytes
+	SCRIPTmaxsize = 10000
+	// SCRIPTmaxelementsize is the most bytes a script can push as a single stack item
+	SCRIPTmaxelementsize = 520
+	// SCRIPTmaxstacksize is the most items the stack can hold while running a script
+	SCRIPTmaxstacksize = 1000
+	// SCRIPTmaxpubkeys is the most public keys a multisig script can check signatures against
+	SCRIPTmaxpubkeys = 20
+	// SCRIPTmaxsignaturesize is the most bytes a DER encoded signature can take, with 33 byte R and S
+	SCRIPTmaxsignaturesize = 72
 
 	// CLIcreateblockchain is the command to create a new DB
 	CLIcreateblockchain = "createblockchain"
@@ -29,6 +80,32 @@ const (
 	CLInewwallet = "newwallet"
 	// CLIprintwallets is the command for showing all public addresses
 	CLIprintwallets = "printwallets"
+	// CLIstartnode is the command for running a long-lived network node
+	CLIstartnode = "startnode"
+	// CLIvalidatechain is the command for re-checking every block in the chain
+	CLIvalidatechain = "validatechain"
+	// CLIprintpendingtransactions is the command for showing the transactions pooled by a node
+	CLIprintpendingtransactions = "printpendingtransactions"
+	// CLIreindex is the command for rebuilding the UTXO set and indexes from the stored blocks
+	CLIreindex = "reindex"
+	// CLIsupply is the command for comparing the coins in circulation to the issuance schedule
+	CLIsupply = "supply"
+	// CLIcreatemultisig is the command for creating an address spendable with M of N signatures
+	CLIcreatemultisig = "createmultisig"
+	// CLIcreatemultisigtx is the command for creating an unsigned transaction spending from a multisig address
+	CLIcreatemultisigtx = "createmultisigtx"
+	// CLIsigntx is the command for adding a wallet's signatures to a serialized transaction
+	CLIsigntx = "signtx"
+	// CLIsendrawtx is the command for submitting or mining a serialized transaction
+	CLIsendrawtx = "sendrawtx"
+	// CLIrestorewallet is the command for rebuilding the wallets derived from a mnemonic
+	CLIrestorewallet = "restorewallet"
+	// CLIchangepassphrase is the command for re-encrypting the wallet file with a new passphrase
+	CLIchangepassphrase = "changepassphrase"
+	// CLIgenerate is the command for mining blocks on demand on regtest
+	CLIgenerate = "generate"
+	// CLImininginfo is the command for showing the difficulty, network hashrate and a node's mining stats
+	CLImininginfo = "mininginfo"
 
 	// CLIaddress is an option flag for an address
 	CLIaddress = "address"
@@ -38,13 +115,178 @@ const (
 	CLIto = "to"
 	// CLIamount is the option flag for an amount of coins
 	CLIamount = "amount"
+	// CLIfee is the option flag for the fee a transaction leaves for the miner
+	CLIfee = "fee"
+	// CLIport is the option flag for the port a node listens on
+	CLIport = "port"
+	// CLIhost is the option flag for the host name peers reach a node at
+	CLIhost = "host"
+	// CLIminer is the option flag for the address mining rewards are paid to
+	CLIminer = "miner"
+	// CLIseeds is the option flag for a comma separated list of peers to connect to on startup
+	CLIseeds = "seeds"
+	// CLInode is the option flag for the address of a running node
+	CLInode = "node"
+	// CLIstart is the option flag for the first height of a range of blocks
+	CLIstart = "start"
+	// CLIend is the option flag for the last height of a range of blocks
+	CLIend = "end"
+	// CLIblocks is the option flag for a number of blocks
+	CLIblocks = "blocks"
+	// CLIrequired is the option flag for the number of signatures a multisig address requires
+	CLIrequired = "required"
+	// CLIpubkeys is the option flag for a comma separated list of hex public keys
+	CLIpubkeys = "pubkeys"
+	// CLItx is the option flag for a hex serialized transaction
+	CLItx = "tx"
+	// CLImnemonic is the option flag for the BIP-39 mnemonic a wallet's keys are derived from
+	CLImnemonic = "mnemonic"
+	// CLIpassphrasefile is the option flag for a file holding the wallet passphrase, instead of prompting for it
+	CLIpassphrasefile = "passphrase-file"
+	// CLInewpassphrasefile is the option flag for a file holding a new wallet passphrase
+	CLInewpassphrasefile = "new-passphrase-file"
+	// CLIcurve is the option flag for the elliptic curve a new key is on
+	CLIcurve = "curve"
+	// CLIsighash is the option flag for the sighash type signatures are made with
+	CLIsighash = "sighash"
+	// CLIdefaultsighash is the sighash type signatures are made with unless another is asked for
+	CLIdefaultsighash = "ALL"
+
+	// CLIexitfailure is the exit code for bad usage and errors without a code of their own
+	CLIexitfailure = 1
+	// CLIexitnochain is the exit code when there is no chain to open
+	CLIexitnochain = 2
+	// CLIexitchainexists is the exit code when creating a chain that already exists
+	CLIexitchainexists = 3
+	// CLIexitwallet is the exit code when the wallet can't be opened with the passphrase or lacks an address
+	CLIexitwallet = 4
+	// CLIexitinsufficientfunds is the exit code when an address can't cover a payment
+	CLIexitinsufficientfunds = 5
+	// CLIexitinvalid is the exit code when a transaction, block or address is rejected
+	CLIexitinvalid = 6
+	// CLIexitcorrupt is the exit code when stored data can't be read or is missing
+	CLIexitcorrupt = 7
+	// CLIexitinuse is the exit code when a running node holds the chain's database
+	CLIexitinuse = 8
 
 	// Version is the 1-byte version of the wallet.
 	Version = byte(0x00)
+	// ScriptHashVersion is the 1-byte version of an address paying to the hash of a script instead of a public key
+	ScriptHashVersion = byte(0x05)
+	// TestVersion is the 1-byte version of a public key hash address on testnet and regtest
+	TestVersion = byte(0x6f)
+	// TestScriptHashVersion is the 1-byte version of a script hash address on testnet and regtest
+	TestScriptHashVersion = byte(0xc4)
 	// UncompressedPubKeyPrefix is the 1-byte prefix of an uncompressed public key. Like bitcoin!
 	UncompressedPubKeyPrefix = byte(0x04)
-	// WalletFile is the wallet filename
+	// WalletDefaultCurve is the elliptic curve new keys are on unless another is asked for
+	WalletDefaultCurve = "p256"
+	// WalletFile is the filename of the wallet in a network's data directory
 	WalletFile = "wallet.dat"
+	// WalletMagic is what an encrypted wallet file starts with, telling it apart from a plaintext one
+	WalletMagic = "CHROMA-WALLET-1"
+	// WalletSaltLen is the number of random bytes a wallet passphrase is salted with
+	WalletSaltLen = 16
+	// WalletScryptN is the scrypt CPU/memory cost of deriving a wallet file's key from its passphrase
+	WalletScryptN = 1 << 15
+	// WalletScryptR is the scrypt block size of deriving a wallet file's key
+	WalletScryptR = 8
+	// WalletScryptP is the scrypt parallelization of deriving a wallet file's key
+	WalletScryptP = 1
+	// WalletScryptmaxN, WalletScryptmaxR and WalletScryptmaxP are the largest scrypt parameters a wallet file may ask for,
+	// keeping the memory and time opening it takes bounded (scrypt needs 128*N*r bytes, 256 MiB at the limits)
+	WalletScryptmaxN = 1 << 18
+	WalletScryptmaxR = 8
+	WalletScryptmaxP = 16
 	// AddressChecksumLen is the number of bytes to take after hashing public key for checksum
 	AddressChecksumLen = 4
+
+	// HDseedkey is the HMAC key a seed is hashed with to get the master key of a P-256 key tree (SLIP-10)
+	HDseedkey = "Nist256p1 seed"
+	// HDseedkeysecp256k1 is the HMAC key a seed is hashed with to get the master key of a secp256k1 key tree (BIP-32)
+	HDseedkeysecp256k1 = "Bitcoin seed"
+	// HDpurpose is the purpose level of derivation paths, 44 for BIP-44
+	HDpurpose = 44
+	// HDcointype is the coin type level of derivation paths, 1 being the one shared by testnets and unregistered coins
+	HDcointype = 1
+	// HDgaplimit is the number of unused addresses in a row after which restoring a wallet stops looking for more
+	HDgaplimit = 20
+	// HDmnemonicbits is the bits of entropy in a new mnemonic, 128 giving 12 words
+	HDmnemonicbits = 128
+
+	// CFGmainnet is the name of the main network
+	CFGmainnet = "mainnet"
+	// CFGtestnet is the name of the test network
+	CFGtestnet = "testnet"
+	// CFGregtest is the name of the local regression test network
+	CFGregtest = "regtest"
+	// CFGmainnetport is the port a mainnet node listens on by default
+	CFGmainnetport = 7330
+	// CFGtestnetport is the port a testnet node listens on by default
+	CFGtestnetport = 17330
+	// CFGregtestport is the port a regtest node listens on by default
+	CFGregtestport = 17440
+	// CFGdatadir is the option and config file key for the data directory
+	CFGdatadir = "datadir"
+	// CFGnetwork is the option and config file key for the network name
+	CFGnetwork = "network"
+	// CFGconf is the option for the config file
+	CFGconf = "conf"
+	// CFGfile is the name of the config file read from the data directory unless another is given
+	CFGfile = "chroma.conf"
+	// CFGdefaultdatadir is the data directory, under the user's home directory, used unless another is given
+	CFGdefaultdatadir = ".chroma"
+	// ENVdatadir is the environment variable for the data directory
+	ENVdatadir = "CHROMA_DATADIR"
+	// ENVnetwork is the environment variable for the network name
+	ENVnetwork = "CHROMA_NETWORK"
+	// ENVconf is the environment variable for the config file
+	ENVconf = "CHROMA_CONF"
+
+	// NETprotocol is the transport nodes talk to each other over
+	NETprotocol = "tcp"
+	// NETversion is the version of the node protocol sent in the version handshake
+	NETversion = 1
+	// NETdialtimeout is the number of seconds to wait when connecting to a peer
+	NETdialtimeout = 5
+	// NETmessagetimeout is the number of seconds a peer has to send its message and read any reply
+	NETmessagetimeout = 30
+	// NETmaxmessagesize is the most bytes a node reads of a single message from a peer
+	NETmaxmessagesize = 32 << 20
+	// NETcommandlength is the number of bytes reserved at the start of a message for the command name
+	NETcommandlength = 12
+	// NETcmdversion is the handshake message exchanging protocol version and best height
+	NETcmdversion = "version"
+	// NETcmdgetblocks is the message asking a peer for the hashes of all of its blocks
+	NETcmdgetblocks = "getblocks"
+	// NETcmdinv is the message announcing the hashes of blocks or transactions a node has
+	NETcmdinv = "inv"
+	// NETcmdgetdata is the message requesting a single block or transaction by hash
+	NETcmdgetdata = "getdata"
+	// NETcmdblock is the message carrying a serialized block
+	NETcmdblock = "block"
+	// NETcmdtx is the message carrying a serialized transaction
+	NETcmdtx = "tx"
+	// NETcmdgetmempool is the message asking a node for its pooled transactions, answered on the same connection
+	NETcmdgetmempool = "getmempool"
+	// NETcmdmempool is the reply to getmempool carrying serialized transactions
+	NETcmdmempool = "mempool"
+	// NETcmdgetmininginfo is the message asking a node for its mining info, answered on the same connection
+	NETcmdgetmininginfo = "getmining"
+	// NETcmdmininginfo is the reply to getmining carrying the node's mining info
+	NETcmdmininginfo = "mininginfo"
+	// NETcmdgetcoins is the message asking a node for outputs to pay from, answered on the same connection
+	NETcmdgetcoins = "getcoins"
+	// NETcmdcoins is the reply to getcoins carrying the outputs found and their transactions
+	NETcmdcoins = "coins"
+	// NETcmdsendtx is the message submitting a transaction to a node, answered on the same connection
+	NETcmdsendtx = "sendtx"
+	// NETcmdtxresult is the reply to sendtx saying whether the node accepted the transaction
+	NETcmdtxresult = "txresult"
+	// NETinvblock is the inventory type for blocks
+	NETinvblock = "block"
+	// NETinvtx is the inventory type for transactions
+	NETinvtx = "tx"
+	// NETminetxthreshold is the number of pooled transactions that makes a mining node mine a block
+	NETminetxthreshold = 1
 )
@@ -12,5 +12,4 @@ func main() {
 * TODO
 * 	Implement newtransaction
 * 	Implement mineblock
-*	Implement printpendingtransactions
  */
@@ -0,0 +1,98 @@
+package network
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"time"
+
+	"github.com/casalettoj/chroma/blockchain"
+	conf "github.com/casalettoj/chroma/constants"
+)
+
+// SendTransaction submits a transaction to the node at address to be pooled, relayed and mined.
+// Returns an error wrapping blockchain.ErrInvalidTransaction with the node's reason if the node rejected it.
+func SendTransaction(address string, tx *blockchain.Transaction) error {
+	var msg txResultMessage
+	if err := call(address, newMessage(conf.NETcmdsendtx, txMessage{Transaction: tx.Serialize()}), conf.NETcmdtxresult, &msg); err != nil {
+		return err
+	}
+	if msg.Error != "" {
+		return fmt.Errorf("%w: rejected by %s: %s", blockchain.ErrInvalidTransaction, address, msg.Error)
+	}
+	return nil
+}
+
+// GetCoins asks the node at address for outputs locked to pubKeyHash worth at least amount, along with their
+// transactions, to pay from with blockchain.NewPaymentFromCoins. The coins may fall short if that's all there is.
+func GetCoins(address string, pubKeyHash []byte, amount int) (blockchain.Coins, error) {
+	var msg coinsMessage
+	if err := call(address, newMessage(conf.NETcmdgetcoins, getCoinsMessage{PubKeyHash: pubKeyHash, Amount: amount}), conf.NETcmdcoins, &msg); err != nil {
+		return blockchain.Coins{}, err
+	}
+	if msg.Error != "" {
+		return blockchain.Coins{}, fmt.Errorf("%s failed finding coins: %s", address, msg.Error)
+	}
+	return msg.Coins, nil
+}
+
+// GetPendingTransactions asks the node at address for the transactions in its pool
+func GetPendingTransactions(address string) ([]blockchain.Transaction, error) {
+	var msg mempoolMessage
+	if err := call(address, newMessage(conf.NETcmdgetmempool, getMempoolMessage{}), conf.NETcmdmempool, &msg); err != nil {
+		return nil, err
+	}
+	var txs []blockchain.Transaction
+	for _, encodedTx := range msg.Transactions {
+		tx, err := blockchain.DeserializeTransaction(encodedTx)
+		if err != nil {
+			return nil, err
+		}
+		txs = append(txs, tx)
+	}
+	return txs, nil
+}
+
+// GetMiningInfo asks the node at address for its mining info, estimating the network hashrate over the given
+// number of most recent blocks
+func GetMiningInfo(address string, blocks int) (blockchain.MiningInfo, error) {
+	var msg miningInfoMessage
+	if err := call(address, newMessage(conf.NETcmdgetmininginfo, getMiningInfoMessage{Blocks: blocks}), conf.NETcmdmininginfo, &msg); err != nil {
+		return blockchain.MiningInfo{}, err
+	}
+	return msg.Info, nil
+}
+
+// call sends a message to the node at address and decodes its reply, which must be a command message, into v
+func call(address string, data []byte, command string, v interface{}) error {
+	response, err := request(address, data)
+	if err != nil {
+		return err
+	}
+	if len(response) < conf.NETcommandlength || bytesToCommand(response[:conf.NETcommandlength]) != command {
+		return fmt.Errorf("unexpected response from %s", address)
+	}
+	return decodePayload(response[conf.NETcommandlength:], v)
+}
+
+// request sends a message to the node at address and returns its reply on the same connection
+func request(address string, data []byte) ([]byte, error) {
+	conn, err := net.DialTimeout(conf.NETprotocol, address, conf.NETdialtimeout*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err = conn.SetDeadline(time.Now().Add(conf.NETmessagetimeout * time.Second)); err != nil {
+		return nil, err
+	}
+	if _, err = conn.Write(data); err != nil {
+		return nil, err
+	}
+	// Signal the end of the request so the node stops reading and answers.
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		if err = tcpConn.CloseWrite(); err != nil {
+			return nil, err
+		}
+	}
+	return ioutil.ReadAll(conn)
+}
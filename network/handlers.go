@@ -0,0 +1,278 @@
+package network
+
+import (
+	"errors"
+	"log"
+
+	"github.com/casalettoj/chroma/blockchain"
+	conf "github.com/casalettoj/chroma/constants"
+)
+
+// handleVersion compares heights with a peer: the shorter side asks the longer side for its blocks.
+// Peers on another network are ignored.
+func (s *Server) handleVersion(payload []byte) {
+	var msg versionMessage
+	if err := decodePayload(payload, &msg); err != nil {
+		log.Printf("Bad version message: %v\n", err)
+		return
+	}
+	if msg.Network != s.bc.Network.Name {
+		log.Printf("Ignoring %s, which is on %q instead of %s\n", msg.AddrFrom, msg.Network, s.bc.Network.Name)
+		return
+	}
+
+	bestHeight, err := s.bc.GetBestHeight()
+	if err != nil {
+		log.Printf("Failed reading best height: %v\n", err)
+		return
+	}
+	if bestHeight < msg.BestHeight {
+		s.sendGetBlocks(msg.AddrFrom)
+	} else if bestHeight > msg.BestHeight {
+		s.sendVersion(msg.AddrFrom)
+	}
+	s.addKnownNode(msg.AddrFrom)
+}
+
+// handleGetBlocks answers with an inventory of every block hash in the chain
+func (s *Server) handleGetBlocks(payload []byte) {
+	var msg getBlocksMessage
+	if err := decodePayload(payload, &msg); err != nil {
+		log.Printf("Bad getblocks message: %v\n", err)
+		return
+	}
+	hashes, err := s.bc.GetBlockHashes()
+	if err != nil {
+		log.Printf("Failed reading block hashes: %v\n", err)
+		return
+	}
+	s.sendInv(msg.AddrFrom, conf.NETinvblock, hashes)
+}
+
+// handleInv requests any announced blocks or transactions we don't already have
+func (s *Server) handleInv(payload []byte) {
+	var msg invMessage
+	if err := decodePayload(payload, &msg); err != nil {
+		log.Printf("Bad inv message: %v\n", err)
+		return
+	}
+
+	switch msg.Type {
+	case conf.NETinvblock:
+		// Inventories list the tip first; fetch the missing blocks oldest first so each one extends our tip.
+		var missing [][]byte
+		for i := len(msg.Items) - 1; i >= 0; i-- {
+			stored, err := s.bc.HasBlock(msg.Items[i])
+			if err != nil {
+				log.Printf("Failed looking up block %x: %v\n", msg.Items[i], err)
+				return
+			}
+			if !stored {
+				missing = append(missing, msg.Items[i])
+			}
+		}
+		s.blocksInTransit = missing
+		if len(missing) == 0 {
+			s.syncing = false
+		}
+		s.requestNextBlock(msg.AddrFrom)
+	case conf.NETinvtx:
+		for _, id := range msg.Items {
+			if !s.mempool.Has(id) {
+				s.sendGetData(msg.AddrFrom, conf.NETinvtx, id)
+			}
+		}
+	}
+}
+
+// handleGetData sends the requested block or pooled transaction
+func (s *Server) handleGetData(payload []byte) {
+	var msg getDataMessage
+	if err := decodePayload(payload, &msg); err != nil {
+		log.Printf("Bad getdata message: %v\n", err)
+		return
+	}
+
+	switch msg.Type {
+	case conf.NETinvblock:
+		block, err := s.bc.GetBlock(msg.ID)
+		if err != nil {
+			return
+		}
+		s.sendBlock(msg.AddrFrom, &block)
+	case conf.NETinvtx:
+		tx, ok := s.mempool.Get(msg.ID)
+		if !ok {
+			return
+		}
+		s.sendTx(msg.AddrFrom, &tx)
+	}
+}
+
+// handleBlock adds a block from a peer to the chain, then continues any sync in progress
+func (s *Server) handleBlock(payload []byte) {
+	var msg blockMessage
+	if err := decodePayload(payload, &msg); err != nil {
+		log.Printf("Bad block message: %v\n", err)
+		return
+	}
+	block, err := blockchain.DeserializeBlock(msg.Block)
+	if err != nil {
+		log.Printf("Bad block message: %v\n", err)
+		return
+	}
+
+	disconnected, connected, err := s.bc.AddBlock(block)
+	// A block whose parent we don't have isn't invalid, it just can't be placed yet
+	if err != nil && !errors.Is(err, blockchain.ErrBlockNotFound) {
+		log.Printf("Rejected block %x: %v\n", block.Hash, err)
+	}
+	stored, storedErr := s.bc.HasBlock(block.Hash)
+	if storedErr != nil {
+		log.Printf("Failed looking up block %x: %v\n", block.Hash, storedErr)
+		return
+	}
+	if err == nil && stored && len(connected) == 0 {
+		log.Printf("Stored block %x on a side branch\n", block.Hash)
+	}
+	if len(connected) > 0 {
+		log.Printf("Added block %x\n", block.Hash)
+		if len(disconnected) > 0 {
+			log.Printf("Reorganized %d blocks onto %x\n", len(disconnected), block.Hash)
+		}
+		// Whatever was being mined no longer builds on the tip
+		s.stopMining()
+		// Transactions from blocks that left the main chain go back in the pool if they are still valid
+		for _, b := range connected {
+			s.mempool.RemoveBlock(b)
+		}
+		for _, b := range disconnected {
+			for _, tx := range b.Transactions[1:] {
+				s.mempool.Add(s.bc, tx)
+			}
+		}
+		if len(s.blocksInTransit) == 0 {
+			s.syncing = false
+			s.broadcastInv(conf.NETinvblock, [][]byte{block.Hash}, msg.AddrFrom)
+			s.startMining()
+		}
+	} else if !stored {
+		// The block doesn't connect to anything we have. If we aren't already syncing, we may be more than one block
+		// behind this peer so ask for everything it has; otherwise give up on this peer's chain.
+		s.blocksInTransit = nil
+		if !s.syncing && errors.Is(err, blockchain.ErrBlockNotFound) {
+			s.sendGetBlocks(msg.AddrFrom)
+		} else {
+			s.syncing = false
+		}
+		return
+	}
+	s.requestNextBlock(msg.AddrFrom)
+}
+
+// handleTx verifies a transaction from a peer, adds it to the pool and relays it to the other known nodes
+func (s *Server) handleTx(payload []byte) {
+	var msg txMessage
+	if err := decodePayload(payload, &msg); err != nil {
+		log.Printf("Bad tx message: %v\n", err)
+		return
+	}
+	tx, err := blockchain.DeserializeTransaction(msg.Transaction)
+	if err != nil {
+		log.Printf("Bad tx message: %v\n", err)
+		return
+	}
+
+	if s.mempool.Has(tx.ID) {
+		return
+	}
+	if err := s.acceptTx(&tx, msg.AddrFrom); err != nil {
+		log.Printf("Rejected tx %x: %v\n", tx.ID, err)
+	}
+}
+
+// handleSendTx pools and relays a transaction submitted by a client as handleTx does, replying with whether it
+// was accepted
+func (s *Server) handleSendTx(payload []byte) []byte {
+	var reply txResultMessage
+	var msg txMessage
+	err := decodePayload(payload, &msg)
+	var tx blockchain.Transaction
+	if err == nil {
+		tx, err = blockchain.DeserializeTransaction(msg.Transaction)
+	}
+	if err == nil {
+		err = s.acceptTx(&tx, msg.AddrFrom)
+	}
+	if err != nil {
+		log.Printf("Rejected tx %x: %v\n", tx.ID, err)
+		reply.Error = err.Error()
+	}
+	return newMessage(conf.NETcmdtxresult, reply)
+}
+
+// acceptTx pools a transaction, announces it to every known node other than from and starts mining if enough
+// txs are pooled. Returns why the pool rejected the transaction, if it did.
+func (s *Server) acceptTx(tx *blockchain.Transaction, from string) error {
+	if err := s.mempool.Add(s.bc, tx); err != nil {
+		return err
+	}
+	s.broadcastInv(conf.NETinvtx, [][]byte{tx.ID}, from)
+	s.startMining()
+	return nil
+}
+
+// handleGetCoins replies with outputs locked to the requested pubkey hash that add up to the requested amount,
+// along with their transactions, so a client can pay from them without its own copy of the chain. Outputs pooled
+// txs spend are left out, so payments sent one after another don't conflict.
+func (s *Server) handleGetCoins(payload []byte) []byte {
+	var reply coinsMessage
+	var msg getCoinsMessage
+	err := decodePayload(payload, &msg)
+	if err == nil {
+		reply.Coins, err = blockchain.FindCoins(s.bc, msg.PubKeyHash, msg.Amount, s.mempool.Spends)
+	}
+	if err != nil {
+		log.Printf("Failed finding coins: %v\n", err)
+		reply.Error = err.Error()
+	}
+	return newMessage(conf.NETcmdcoins, reply)
+}
+
+// handleGetMiningInfo replies with the node's mining info, with its miner's stats if it is mining
+func (s *Server) handleGetMiningInfo(payload []byte) []byte {
+	var msg getMiningInfoMessage
+	if err := decodePayload(payload, &msg); err != nil {
+		log.Printf("Bad getmining message: %v\n", err)
+		return nil
+	}
+	info, err := s.bc.GetMiningInfo(msg.Blocks)
+	if err != nil {
+		log.Printf("Failed reading mining info: %v\n", err)
+		return nil
+	}
+	if s.MinerAddress != "" {
+		stats := s.Miner.Stats()
+		info.Miner = &stats
+	}
+	return newMessage(conf.NETcmdmininginfo, miningInfoMessage{Info: info})
+}
+
+// requestNextBlock asks a peer for the next block waiting to be synced, if any
+func (s *Server) requestNextBlock(address string) {
+	if len(s.blocksInTransit) == 0 {
+		return
+	}
+	hash := s.blocksInTransit[0]
+	s.blocksInTransit = s.blocksInTransit[1:]
+	s.sendGetData(address, conf.NETinvblock, hash)
+}
+
+// handleGetMempool replies with every pooled transaction
+func (s *Server) handleGetMempool() []byte {
+	var payload mempoolMessage
+	for _, tx := range s.mempool.Transactions() {
+		payload.Transactions = append(payload.Transactions, tx.Serialize())
+	}
+	return newMessage(conf.NETcmdmempool, payload)
+}
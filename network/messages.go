@@ -0,0 +1,114 @@
+package network
+
+import (
+	"bytes"
+	"encoding/gob"
+
+	"github.com/casalettoj/chroma/blockchain"
+	conf "github.com/casalettoj/chroma/constants"
+	util "github.com/casalettoj/chroma/utils"
+)
+
+// versionMessage is the handshake a node sends to introduce itself, the network it is on and its best height
+type versionMessage struct {
+	Version    int
+	Network    string
+	BestHeight int
+	AddrFrom   string
+}
+
+// getBlocksMessage asks a peer for the hashes of every block it has
+type getBlocksMessage struct {
+	AddrFrom string
+}
+
+// invMessage announces block or transaction hashes the sender has
+type invMessage struct {
+	AddrFrom string
+	Type     string
+	Items    [][]byte
+}
+
+// getDataMessage requests a single block or transaction by hash
+type getDataMessage struct {
+	AddrFrom string
+	Type     string
+	ID       []byte
+}
+
+// blockMessage carries a block serialized with Block.Serialize
+type blockMessage struct {
+	AddrFrom string
+	Block    []byte
+}
+
+// txMessage carries a transaction serialized with Transaction.Serialize
+type txMessage struct {
+	AddrFrom    string
+	Transaction []byte
+}
+
+// getMempoolMessage asks a node for its pooled transactions
+type getMempoolMessage struct {
+	AddrFrom string
+}
+
+// mempoolMessage carries every pooled transaction, serialized with Transaction.Serialize
+type mempoolMessage struct {
+	Transactions [][]byte
+}
+
+// getMiningInfoMessage asks a node for its mining info, estimating the network hashrate over Blocks blocks
+type getMiningInfoMessage struct {
+	AddrFrom string
+	Blocks   int
+}
+
+// miningInfoMessage carries a node's mining info
+type miningInfoMessage struct {
+	Info blockchain.MiningInfo
+}
+
+// getCoinsMessage asks a node for outputs locked to PubKeyHash worth at least Amount
+type getCoinsMessage struct {
+	AddrFrom   string
+	PubKeyHash []byte
+	Amount     int
+}
+
+// coinsMessage carries the outputs a node found to pay from, or why it couldn't look for them
+type coinsMessage struct {
+	Coins blockchain.Coins
+	Error string
+}
+
+// txResultMessage says whether a node accepted a transaction, giving why not if it didn't
+type txResultMessage struct {
+	Error string
+}
+
+// commandToBytes pads a command name out to NETcommandlength bytes
+func commandToBytes(name string) []byte {
+	var command [conf.NETcommandlength]byte
+	copy(command[:], name)
+	return command[:]
+}
+
+// bytesToCommand strips the padding from a command name
+func bytesToCommand(bbytes []byte) string {
+	return string(bytes.TrimRight(bbytes, "\x00"))
+}
+
+// newMessage returns the wire form of a message: the padded command followed by the gob encoded payload
+func newMessage(command string, payload interface{}) []byte {
+	var buffer bytes.Buffer
+	encoder := gob.NewEncoder(&buffer)
+	util.CheckAnxiety(encoder.Encode(payload))
+	return append(commandToBytes(command), buffer.Bytes()...)
+}
+
+// decodePayload decodes a gob encoded message payload into v
+func decodePayload(payload []byte, v interface{}) error {
+	decoder := gob.NewDecoder(bytes.NewReader(payload))
+	return decoder.Decode(v)
+}
@@ -0,0 +1,89 @@
+package network
+
+import (
+	"context"
+	"log"
+
+	"github.com/casalettoj/chroma/blockchain"
+	conf "github.com/casalettoj/chroma/constants"
+)
+
+// startMining starts mining the best paying pooled transactions into a new block paying MinerAddress their fees
+// on top of the subsidy, in the background so the node keeps handling messages meanwhile. Does nothing on a
+// non-mining node, while a block is already being mined or until NETminetxthreshold txs are pooled.
+// Must be called with the mutex held.
+func (s *Server) startMining() {
+	if s.MinerAddress == "" || s.cancelMining != nil || s.mempool.Size() < conf.NETminetxthreshold {
+		return
+	}
+	txs, fees, err := s.mempool.SelectForBlock(s.bc, conf.TXblockmaxtxs)
+	if err != nil {
+		log.Printf("Failed selecting pooled txs: %v\n", err)
+		return
+	}
+	if len(txs) == 0 {
+		return
+	}
+	bestHeight, err := s.bc.GetBestHeight()
+	if err != nil {
+		log.Printf("Failed reading best height: %v\n", err)
+		return
+	}
+
+	coinbaseTx, err := blockchain.NewCoinbaseTx(s.bc.Network, s.MinerAddress, "", bestHeight+1, fees)
+	if err != nil {
+		log.Printf("Failed paying %s: %v\n", s.MinerAddress, err)
+		return
+	}
+	txs = append([]*blockchain.Transaction{coinbaseTx}, txs...)
+	block, err := s.bc.NewBlockTemplate(txs)
+	if err != nil {
+		log.Printf("Failed mining block: %v\n", err)
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancelMining = cancel
+	go s.mine(ctx, block)
+}
+
+// stopMining abandons the block being mined, if any, e.g. because the tip moved and it would be stale.
+// Must be called with the mutex held.
+func (s *Server) stopMining() {
+	if s.cancelMining != nil {
+		s.cancelMining()
+		s.cancelMining = nil
+	}
+}
+
+// mine finds the proof of work for a block from startMining, then adds it to the chain, announces it to peers
+// and starts on the next block if enough txs are still pooled
+func (s *Server) mine(ctx context.Context, block *blockchain.Block) {
+	err := s.Miner.Mine(ctx, block)
+
+	defer s.flush()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	// stopMining cancels with the mutex held, so a cancelled block may also have been mined just before. It also
+	// clears cancelMining, which may since belong to a newer block, so only an uncancelled block clears it here.
+	if ctx.Err() != nil {
+		return
+	}
+	s.cancelMining = nil
+	if err != nil {
+		log.Printf("Failed mining block: %v\n", err)
+		return
+	}
+	if s.closed {
+		return
+	}
+
+	if _, _, err = s.bc.AddBlock(block); err != nil {
+		log.Printf("Failed adding mined block: %v\n", err)
+		return
+	}
+	s.mempool.RemoveBlock(block)
+
+	log.Printf("Mined block %x\n", block.Hash)
+	s.broadcastInv(conf.NETinvblock, [][]byte{block.Hash}, "")
+	s.startMining()
+}
@@ -0,0 +1,101 @@
+package network
+
+import (
+	"log"
+	"net"
+	"time"
+
+	"github.com/casalettoj/chroma/blockchain"
+	conf "github.com/casalettoj/chroma/constants"
+)
+
+// outgoing is a message queued for a peer
+type outgoing struct {
+	address string
+	data    []byte
+}
+
+// sendData queues a message for a peer. Dialing can take up to NETdialtimeout, so messages aren't sent until flush
+// runs after the mutex is released, and a slow or unreachable peer doesn't hold up the rest of the node.
+// Must be called with the mutex held.
+func (s *Server) sendData(address string, data []byte) {
+	s.outbox = append(s.outbox, outgoing{address: address, data: data})
+}
+
+// flush sends the queued messages in the order they were queued, forgetting peers that can't be reached.
+// Once the node is closed queued messages are dropped. Must be called without the mutex held.
+func (s *Server) flush() {
+	s.mutex.Lock()
+	outbox := s.outbox
+	s.outbox = nil
+	closed := s.closed
+	s.mutex.Unlock()
+	if closed {
+		return
+	}
+
+	for _, msg := range outbox {
+		conn, err := net.DialTimeout(conf.NETprotocol, msg.address, conf.NETdialtimeout*time.Second)
+		if err != nil {
+			log.Printf("%s is not available\n", msg.address)
+			s.mutex.Lock()
+			s.removeKnownNode(msg.address)
+			s.mutex.Unlock()
+			continue
+		}
+		if _, err = conn.Write(msg.data); err != nil {
+			log.Printf("Failed sending to %s: %v\n", msg.address, err)
+		}
+		conn.Close()
+	}
+}
+
+// sendVersion sends our protocol version and best height to a peer
+func (s *Server) sendVersion(address string) {
+	bestHeight, err := s.bc.GetBestHeight()
+	if err != nil {
+		log.Printf("Failed reading best height: %v\n", err)
+		return
+	}
+	payload := versionMessage{Version: conf.NETversion, Network: s.bc.Network.Name, BestHeight: bestHeight, AddrFrom: s.Address}
+	s.sendData(address, newMessage(conf.NETcmdversion, payload))
+}
+
+// sendGetBlocks asks a peer for the hashes of its blocks, marking the node as syncing
+func (s *Server) sendGetBlocks(address string) {
+	s.syncing = true
+	s.sendData(address, newMessage(conf.NETcmdgetblocks, getBlocksMessage{AddrFrom: s.Address}))
+}
+
+// sendInv announces block or transaction hashes to a peer
+func (s *Server) sendInv(address, kind string, items [][]byte) {
+	payload := invMessage{AddrFrom: s.Address, Type: kind, Items: items}
+	s.sendData(address, newMessage(conf.NETcmdinv, payload))
+}
+
+// sendGetData requests a block or transaction from a peer
+func (s *Server) sendGetData(address, kind string, id []byte) {
+	payload := getDataMessage{AddrFrom: s.Address, Type: kind, ID: id}
+	s.sendData(address, newMessage(conf.NETcmdgetdata, payload))
+}
+
+// sendBlock sends a full block to a peer
+func (s *Server) sendBlock(address string, block *blockchain.Block) {
+	payload := blockMessage{AddrFrom: s.Address, Block: block.Serialize()}
+	s.sendData(address, newMessage(conf.NETcmdblock, payload))
+}
+
+// sendTx sends a full transaction to a peer
+func (s *Server) sendTx(address string, tx *blockchain.Transaction) {
+	payload := txMessage{AddrFrom: s.Address, Transaction: tx.Serialize()}
+	s.sendData(address, newMessage(conf.NETcmdtx, payload))
+}
+
+// broadcastInv announces hashes to every known node other than except
+func (s *Server) broadcastInv(kind string, items [][]byte, except string) {
+	for _, node := range append([]string{}, s.KnownNodes...) {
+		if node != except {
+			s.sendInv(node, kind, items)
+		}
+	}
+}
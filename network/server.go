@@ -0,0 +1,180 @@
+package network
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/casalettoj/chroma/blockchain"
+	conf "github.com/casalettoj/chroma/constants"
+)
+
+// Server is a CHROMA node that listens for peers and keeps its chain in sync with theirs
+type Server struct {
+	Address    string
+	KnownNodes []string
+	// MinerAddress receives the coinbase of blocks mined from the pool. Leave empty to run a non-mining node.
+	MinerAddress string
+	// Miner finds the proof of work of the blocks the node mines
+	Miner *blockchain.Miner
+
+	bc              *blockchain.Blockchain
+	mempool         *blockchain.Mempool
+	blocksInTransit [][]byte
+	syncing         bool
+	closed          bool
+	listener        net.Listener
+	// outbox holds the messages queued for peers while the mutex is held, see sendData
+	outbox []outgoing
+	// cancelMining abandons the block being mined, nil while none is
+	cancelMining context.CancelFunc
+	mutex        sync.Mutex
+}
+
+// NewServer returns a node for the given chain that will listen on address and introduce itself to seeds
+func NewServer(address string, bc *blockchain.Blockchain, seeds []string) *Server {
+	return &Server{
+		Address:    address,
+		KnownNodes: append([]string{}, seeds...),
+		Miner:      blockchain.DefaultMiner,
+		bc:         bc,
+		mempool:    blockchain.NewMempool(),
+	}
+}
+
+// Start begins accepting peer connections in the background and sends a version handshake to every known node.
+// If Address has port 0 a free port is picked, and Address is updated to have the port being listened on.
+func (s *Server) Start() error {
+	listener, err := net.Listen(conf.NETprotocol, s.Address)
+	if err != nil {
+		return err
+	}
+	defer s.flush()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.listener = listener
+	if host, port, err := net.SplitHostPort(s.Address); err == nil && port == "0" {
+		_, port, _ = net.SplitHostPort(listener.Addr().String())
+		s.Address = net.JoinHostPort(host, port)
+	}
+	go s.serve()
+
+	for _, node := range s.KnownNodes {
+		s.sendVersion(node)
+	}
+	return nil
+}
+
+// Close stops accepting peer connections and mining, and waits for the message being handled, if any, to finish
+func (s *Server) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.closed = true
+	s.stopMining()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+// serve accepts connections until the listener is closed
+func (s *Server) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handleConnection(conn)
+	}
+}
+
+// handleConnection reads a single message from a peer and handles it. Any reply and the messages queued for peers
+// while handling it are written once the mutex is released, so a slow peer can only hold up its own connection.
+func (s *Server) handleConnection(conn net.Conn) {
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(conf.NETmessagetimeout * time.Second)); err != nil {
+		return
+	}
+	request, err := ioutil.ReadAll(io.LimitReader(conn, conf.NETmaxmessagesize+1))
+	if err != nil || len(request) < conf.NETcommandlength || len(request) > conf.NETmaxmessagesize {
+		log.Printf("Dropping malformed message from %s\n", conn.RemoteAddr())
+		return
+	}
+	command := bytesToCommand(request[:conf.NETcommandlength])
+	payload := request[conf.NETcommandlength:]
+
+	reply := s.handleMessage(command, payload, conn.RemoteAddr())
+	if reply != nil {
+		if _, err = conn.Write(reply); err != nil {
+			log.Printf("Failed replying to %s: %v\n", conn.RemoteAddr(), err)
+		}
+	}
+	s.flush()
+}
+
+// handleMessage dispatches a message from a peer on its command, returning the reply to send back, if any
+func (s *Server) handleMessage(command string, payload []byte, from net.Addr) []byte {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return nil
+	}
+
+	switch command {
+	case conf.NETcmdversion:
+		s.handleVersion(payload)
+	case conf.NETcmdgetblocks:
+		s.handleGetBlocks(payload)
+	case conf.NETcmdinv:
+		s.handleInv(payload)
+	case conf.NETcmdgetdata:
+		s.handleGetData(payload)
+	case conf.NETcmdblock:
+		s.handleBlock(payload)
+	case conf.NETcmdtx:
+		s.handleTx(payload)
+	case conf.NETcmdgetmempool:
+		return s.handleGetMempool()
+	case conf.NETcmdgetmininginfo:
+		return s.handleGetMiningInfo(payload)
+	case conf.NETcmdgetcoins:
+		return s.handleGetCoins(payload)
+	case conf.NETcmdsendtx:
+		return s.handleSendTx(payload)
+	default:
+		log.Printf("Unknown command %q from %s\n", command, from)
+	}
+	return nil
+}
+
+// isKnownNode returns whether address is in the known nodes list
+func (s *Server) isKnownNode(address string) bool {
+	for _, node := range s.KnownNodes {
+		if node == address {
+			return true
+		}
+	}
+	return false
+}
+
+// addKnownNode adds address to the known nodes list if it isn't already there
+func (s *Server) addKnownNode(address string) {
+	if address != s.Address && !s.isKnownNode(address) {
+		s.KnownNodes = append(s.KnownNodes, address)
+	}
+}
+
+// removeKnownNode drops address from the known nodes list
+func (s *Server) removeKnownNode(address string) {
+	var nodes []string
+	for _, node := range s.KnownNodes {
+		if node != address {
+			nodes = append(nodes, node)
+		}
+	}
+	s.KnownNodes = nodes
+}
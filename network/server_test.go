@@ -0,0 +1,247 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/casalettoj/chroma/blockchain"
+	"github.com/casalettoj/chroma/config"
+	"github.com/casalettoj/chroma/wallet"
+)
+
+// newTestChains creates a regtest chain whose genesis coinbase pays w and copies it into n-1 more data directories,
+// returning the config of each copy, so nodes started on them share a genesis block
+func newTestChains(t *testing.T, n int, w *wallet.Wallet) []*config.Config {
+	t.Helper()
+	var cfgs []*config.Config
+	for i := 0; i < n; i++ {
+		cfgs = append(cfgs, &config.Config{DataDir: t.TempDir(), Network: &config.Regtest})
+	}
+	bc, err := blockchain.CreateBlockchain(cfgs[0], string(w.GetChromaAddress(&config.Regtest)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc.DB.Close()
+	db, err := ioutil.ReadFile(cfgs[0].DBFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cfg := range cfgs[1:] {
+		if err = os.MkdirAll(filepath.Dir(cfg.DBFile()), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(cfg.DBFile(), db, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return cfgs
+}
+
+// startTestNode opens the chain and starts a node for it on a free local port, introducing it to seeds
+func startTestNode(t *testing.T, cfg *config.Config, seeds ...string) *Server {
+	t.Helper()
+	bc, err := blockchain.OpenBlockchain(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer("127.0.0.1:0", bc, seeds)
+	if err = s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		s.Close()
+		bc.DB.Close()
+	})
+	return s
+}
+
+// waitFor polls until done returns true, failing the test if it takes too long
+func waitFor(t *testing.T, what string, done func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(10 * time.Second); !done(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// tipOf returns the hash of a node's tip, read under its mutex
+func tipOf(s *Server) []byte {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.bc.Tip
+}
+
+func TestPropagation(t *testing.T) {
+	w := wallet.NewWallet(wallet.P256)
+	from := string(w.GetChromaAddress(&config.Regtest))
+	to := string(wallet.NewWallet(wallet.P256).GetChromaAddress(&config.Regtest))
+	cfgs := newTestChains(t, 3, w)
+
+	// a is ahead of the others, which sync from it through one another: c only knows b
+	a := startTestNode(t, cfgs[0])
+	genesis := tipOf(a)
+	generated, err := a.bc.Generate(a.bc.Network.CoinbaseMaturity+1, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tip := generated[len(generated)-1].Hash
+	b := startTestNode(t, cfgs[1], a.Address)
+	c := startTestNode(t, cfgs[2], b.Address)
+	c.mutex.Lock()
+	c.MinerAddress = to
+	c.mutex.Unlock()
+	for _, s := range []*Server{b, c} {
+		waitFor(t, "blocks to reach "+s.Address, func() bool { return bytes.Equal(tipOf(s), tip) })
+	}
+
+	// A tx sent to a is relayed to c, which mines it into a block that makes its way back to a
+	out, err := blockchain.NewUTXO(&config.Regtest, 10, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	genesisBlock, err := a.bc.GetBlock(genesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := &blockchain.Transaction{
+		Vin:  []blockchain.TxInput{{TxID: genesisBlock.Transactions[0].ID, Vout: 0}},
+		Vout: []blockchain.TxOutput{*out},
+	}
+	tx.ID = tx.Hash()
+	if _, err = a.bc.SignTransaction(tx, w, blockchain.SigHashAll); err != nil {
+		t.Fatal(err)
+	}
+	if err = SendTransaction(a.Address, tx); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []*Server{a, b, c} {
+		waitFor(t, "the tx to be mined on "+s.Address, func() bool {
+			s.mutex.Lock()
+			defer s.mutex.Unlock()
+			_, err := s.bc.FindTransaction(tx.ID)
+			return err == nil && s.mempool.Size() == 0
+		})
+	}
+	balance, err := a.bc.GetBalance(from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance != 0 {
+		t.Errorf("%s still has %d", from, balance)
+	}
+}
+
+func TestCloseUnstarted(t *testing.T) {
+	if err := NewServer("127.0.0.1:0", nil, nil).Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMineErrorResets(t *testing.T) {
+	s := NewServer("127.0.0.1:0", nil, nil)
+	unmineable := &blockchain.Block{Bits: 0}
+
+	// A block that fails to mine lets the next one start
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.cancelMining = cancel
+	s.mine(ctx, unmineable)
+	if s.cancelMining != nil {
+		t.Error("a failed block is still being mined")
+	}
+
+	// A cancelled block leaves the block that replaced it alone
+	stale, cancelStale := context.WithCancel(context.Background())
+	cancelStale()
+	_, s.cancelMining = context.WithCancel(context.Background())
+	s.mine(stale, unmineable)
+	if s.cancelMining == nil {
+		t.Error("a cancelled block stopped the block that replaced it")
+	}
+}
+
+func TestSendViaNode(t *testing.T) {
+	w := wallet.NewWallet(wallet.P256)
+	to := string(wallet.NewWallet(wallet.P256).GetChromaAddress(&config.Regtest))
+	cfgs := newTestChains(t, 1, w)
+	s := startTestNode(t, cfgs[0])
+	if _, err := s.bc.Generate(s.bc.Network.CoinbaseMaturity, to); err != nil {
+		t.Fatal(err)
+	}
+
+	// The node finds the coins and the tx signed with them is accepted
+	coins, err := GetCoins(s.Address, wallet.HashPublicKey(w.PublicKey), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := blockchain.NewPaymentFromCoins(&config.Regtest, w, coins, to, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = SendTransaction(s.Address, tx); err != nil {
+		t.Fatal(err)
+	}
+	s.mutex.Lock()
+	pooled := s.mempool.Has(tx.ID)
+	s.mutex.Unlock()
+	if !pooled {
+		t.Error("accepted tx isn't pooled")
+	}
+
+	// Sending it again is rejected
+	if err = SendTransaction(s.Address, tx); !errors.Is(err, blockchain.ErrInvalidTransaction) {
+		t.Errorf("resending a pooled tx gave %v, want ErrInvalidTransaction", err)
+	}
+}
+
+func TestSendTwiceViaNode(t *testing.T) {
+	w := wallet.NewWallet(wallet.P256)
+	from := string(w.GetChromaAddress(&config.Regtest))
+	to := string(wallet.NewWallet(wallet.P256).GetChromaAddress(&config.Regtest))
+	cfgs := newTestChains(t, 1, w)
+	s := startTestNode(t, cfgs[0])
+	if _, err := s.bc.Generate(1, from); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.bc.Generate(s.bc.Network.CoinbaseMaturity, to); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second payment can't use the output the first one spends while it is still pooled
+	for i := 0; i < 2; i++ {
+		coins, err := GetCoins(s.Address, wallet.HashPublicKey(w.PublicKey), 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx, err := blockchain.NewPaymentFromCoins(&config.Regtest, w, coins, to, 10, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = SendTransaction(s.Address, tx); err != nil {
+			t.Fatalf("send %d: %v", i+1, err)
+		}
+	}
+	s.mutex.Lock()
+	pooled := s.mempool.Size()
+	s.mutex.Unlock()
+	if pooled != 2 {
+		t.Errorf("%d txs pooled, want 2", pooled)
+	}
+
+	// Once both coinbases are spent by pooled txs the node has nothing left to pay from
+	coins, err := GetCoins(s.Address, wallet.HashPublicKey(w.PublicKey), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = blockchain.NewPaymentFromCoins(&config.Regtest, w, coins, to, 10, 1); !errors.Is(err, blockchain.ErrInsufficientFunds) {
+		t.Errorf("a third payment gave %v, want ErrInsufficientFunds", err)
+	}
+}
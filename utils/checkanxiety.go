@@ -3,6 +3,7 @@ package utils
 import "log"
 
 // CheckAnxiety detects if we gotta panic.
+// Only for errors that can't happen short of a bug, like encoding an in-memory value; the rest are returned.
 func CheckAnxiety(err error) {
 	if err != nil {
 		log.Panic(err)
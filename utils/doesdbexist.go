@@ -1,18 +1,15 @@
 package utils
 
-import (
-	"os"
+import "os"
 
-	conf "github.com/casalettoj/chroma/constants"
-)
-
-// DoesDBExist checks for an existing blockchain db file
-func DoesDBExist() bool {
-	_, err := os.Stat(conf.DBdbfile)
+// DoesDBExist checks for an existing blockchain db file at the given path
+func DoesDBExist(file string) (bool, error) {
+	_, err := os.Stat(file)
 	if os.IsNotExist(err) {
-		return false
+		return false, nil
 	}
-
-	CheckAnxiety(err)
-	return true
+	if err != nil {
+		return false, err
+	}
+	return true, nil
 }
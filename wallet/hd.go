@@ -0,0 +1,110 @@
+package wallet
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"math/big"
+
+	conf "github.com/casalettoj/chroma/constants"
+)
+
+// HardenedOffset is added to a child index to derive a hardened child, which can't be derived from the parent's public key
+const HardenedOffset uint32 = 0x80000000
+
+// ExtendedKey is a private key along with the chain code needed to derive child keys from it (BIP-32).
+// Derivation follows SLIP-10, which extends BIP-32 to P-256 and is BIP-32 itself on secp256k1.
+type ExtendedKey struct {
+	Curve     KeyCurve
+	Key       []byte
+	ChainCode []byte
+}
+
+// NewMasterKey derives the root key of a key tree on the curve from a seed
+func NewMasterKey(seed []byte, curve KeyCurve) *ExtendedKey {
+	I := hmacSHA512([]byte(curve.seedKey()), seed)
+	// The odds of an invalid key are negligible, but if it happens SLIP-10 hashes again
+	for !validPrivateKey(curve, I[:32]) {
+		I = hmacSHA512([]byte(curve.seedKey()), I)
+	}
+	return &ExtendedKey{Curve: curve, Key: I[:32], ChainCode: I[32:]}
+}
+
+// Child derives the child key at index, which is hardened if it is at least HardenedOffset
+func (k *ExtendedKey) Child(index uint32) *ExtendedKey {
+	curve := k.Curve.Curve()
+	var data []byte
+	if index >= HardenedOffset {
+		data = append([]byte{0x00}, k.Key...)
+	} else {
+		data = compressPublicKey(curve.ScalarBaseMult(k.Key))
+	}
+	data = append(data, ser32(index)...)
+
+	for {
+		I := hmacSHA512(k.ChainCode, data)
+		IL := new(big.Int).SetBytes(I[:32])
+		if IL.Cmp(curve.Params().N) < 0 {
+			childKey := IL.Add(IL, new(big.Int).SetBytes(k.Key))
+			childKey.Mod(childKey, curve.Params().N)
+			if childKey.Sign() != 0 {
+				return &ExtendedKey{Curve: k.Curve, Key: padTo32(childKey.Bytes()), ChainCode: I[32:]}
+			}
+		}
+		// Invalid child, so SLIP-10 derives again from the right half
+		data = append(append([]byte{0x01}, I[32:]...), ser32(index)...)
+	}
+}
+
+// DerivePath derives the key at a path of child indexes below k
+func (k *ExtendedKey) DerivePath(path []uint32) *ExtendedKey {
+	key := k
+	for _, index := range path {
+		key = key.Child(index)
+	}
+	return key
+}
+
+// Wallet returns a wallet holding the extended key's private key
+func (k *ExtendedKey) Wallet() *Wallet {
+	private := newPrivateKey(k.Curve, new(big.Int).SetBytes(k.Key))
+	public := MarshalPublicKey(k.Curve, private.PublicKey.X, private.PublicKey.Y)
+	return &Wallet{Curve: k.Curve, PrivateKey: private, PublicKey: public}
+}
+
+// AddressPath returns the BIP-44 path of the receiving address at index in the first account:
+// m/purpose'/coin_type'/account'/0/index
+func AddressPath(index uint32) []uint32 {
+	return []uint32{
+		conf.HDpurpose + HardenedOffset,
+		conf.HDcointype + HardenedOffset,
+		0 + HardenedOffset,
+		0,
+		index,
+	}
+}
+
+// validPrivateKey returns whether key is a valid private key on the curve: not zero and less than the curve's order
+func validPrivateKey(curve KeyCurve, key []byte) bool {
+	d := new(big.Int).SetBytes(key)
+	return d.Sign() != 0 && d.Cmp(curve.Curve().Params().N) < 0
+}
+
+// padTo32 left pads a big endian number to 32 bytes
+func padTo32(b []byte) []byte {
+	return append(make([]byte, 32-len(b)), b...)
+}
+
+// ser32 serializes an index as 4 big endian bytes
+func ser32(i uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, i)
+	return b
+}
+
+// hmacSHA512 returns the HMAC-SHA512 of data under key
+func hmacSHA512(key, data []byte) []byte {
+	mac := hmac.New(sha512.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
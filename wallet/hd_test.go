@@ -0,0 +1,137 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/casalettoj/chroma/config"
+)
+
+const (
+	// testSeed1 and testSeed2 are the seeds of BIP-32 test vectors 1 and 2, which SLIP-10 uses too
+	testSeed1 = "000102030405060708090a0b0c0d0e0f"
+	testSeed2 = "fffcf9f6f3f0edeae7e4e1dedbd8d5d2cfccc9c6c3c0bdbab7b4b1aeaba8a5a29f9c999693908d8a8784817e7b7875726f6c696663605d5a5754514e4b484542"
+)
+
+func TestDerivePath(t *testing.T) {
+	const h = HardenedOffset
+	for _, test := range []struct {
+		name           string
+		curve          KeyCurve
+		seed           string
+		path           []uint32
+		chainCode, key string
+	}{
+		// BIP-32 test vector 1
+		{"bip32 1", Secp256k1, testSeed1, nil,
+			"873dff81c02f525623fd1fe5167eac3a55a049de3d314bb42ee227ffed37d508", "e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35"},
+		{"bip32 1", Secp256k1, testSeed1, []uint32{0 + h},
+			"47fdacbd0f1097043b78c63c20c34ef4ed9a111d980047ad16282c7ae6236141", "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea"},
+		{"bip32 1", Secp256k1, testSeed1, []uint32{0 + h, 1},
+			"2a7857631386ba23dacac34180dd1983734e444fdbf774041578e9b6adb37c19", "3c6cb8d0f6a264c91ea8b5030fadaa8e538b020f0a387421a12de9319dc93368"},
+		{"bip32 1", Secp256k1, testSeed1, []uint32{0 + h, 1, 2 + h},
+			"04466b9cc8e161e966409ca52986c584f07e9dc81f735db683c3ff6ec7b1503f", "cbce0d719ecf7431d88e6a89fa1483e02e35092af60c042b1df2ff59fa424dca"},
+		{"bip32 1", Secp256k1, testSeed1, []uint32{0 + h, 1, 2 + h, 2},
+			"cfb71883f01676f587d023cc53a35bc7f88f724b1f8c2892ac1275ac822a3edd", "0f479245fb19a38a1954c5c7c0ebab2f9bdfd96a17563ef28a6a4b1a2a764ef4"},
+		{"bip32 1", Secp256k1, testSeed1, []uint32{0 + h, 1, 2 + h, 2, 1000000000},
+			"c783e67b921d2beb8f6b389cc646d7263b4145701dadd2161548a8b078e65e9e", "471b76e389e528d6de6d816857e012c5455051cad6660850e58372a6c3e6e7c8"},
+		// BIP-32 test vector 2
+		{"bip32 2", Secp256k1, testSeed2, nil,
+			"60499f801b896d83179a4374aeb7822aaeaceaa0db1f85ee3e904c4defbd9689", "4b03d6fc340455b363f51020ad3ecca4f0850280cf436c70c727923f6db46c3e"},
+		{"bip32 2", Secp256k1, testSeed2, []uint32{0},
+			"f0909affaa7ee7abe5dd4e100598d4dc53cd709d5a5c2cac40e7412f232f7c9c", "abe74a98f6c7eabee0428f53798f0ab8aa1bd37873999041703c742f15ac7e1e"},
+		{"bip32 2", Secp256k1, testSeed2, []uint32{0, 2147483647 + h},
+			"be17a268474a6bb9c61e1d720cf6215e2a88c5406c4aee7b38547f585c9a37d9", "877c779ad9687164e9c2f4f0f4ff0340814392330693ce95a58fe18fd52e6e93"},
+		{"bip32 2", Secp256k1, testSeed2, []uint32{0, 2147483647 + h, 1},
+			"f366f48f1ea9f2d1d3fe958c95ca84ea18e4c4ddb9366c336c927eb246fb38cb", "704addf544a06e5ee4bea37098463c23613da32020d604506da8c0518e1da4b7"},
+		{"bip32 2", Secp256k1, testSeed2, []uint32{0, 2147483647 + h, 1, 2147483646 + h},
+			"637807030d55d01f9a0cb3a7839515d796bd07706386a6eddf06cc29a65a0e29", "f1c7c871a54a804afe328b4c83a1c33b8e5ff48f5087273f04efa83b247d6a2d"},
+		{"bip32 2", Secp256k1, testSeed2, []uint32{0, 2147483647 + h, 1, 2147483646 + h, 2},
+			"9452b549be8cea3ecb7a84bec10dcfd94afe4d129ebfd3b3cb58eedf394ed271", "bb7d39bdb83ecf58f2fd82b6d918341cbef428661ef01ab97c28a4842125ac23"},
+
+		// SLIP-10 test vector 1 for nist256p1
+		{"slip10 1", P256, testSeed1, nil,
+			"beeb672fe4621673f722f38529c07392fecaa61015c80c34f29ce8b41b3cb6ea", "612091aaa12e22dd2abef664f8a01a82cae99ad7441b7ef8110424915c268bc2"},
+		{"slip10 1", P256, testSeed1, []uint32{0 + h},
+			"3460cea53e6a6bb5fb391eeef3237ffd8724bf0a40e94943c98b83825342ee11", "6939694369114c67917a182c59ddb8cafc3004e63ca5d3b84403ba8613debc0c"},
+		{"slip10 1", P256, testSeed1, []uint32{0 + h, 1},
+			"4187afff1aafa8445010097fb99d23aee9f599450c7bd140b6826ac22ba21d0c", "284e9d38d07d21e4e281b645089a94f4cf5a5a81369acf151a1c3a57f18b2129"},
+		{"slip10 1", P256, testSeed1, []uint32{0 + h, 1, 2 + h},
+			"98c7514f562e64e74170cc3cf304ee1ce54d6b6da4f880f313e8204c2a185318", "694596e8a54f252c960eb771a3c41e7e32496d03b954aeb90f61635b8e092aa7"},
+		{"slip10 1", P256, testSeed1, []uint32{0 + h, 1, 2 + h, 2},
+			"ba96f776a5c3907d7fd48bde5620ee374d4acfd540378476019eab70790c63a0", "5996c37fd3dd2679039b23ed6f70b506c6b56b3cb5e424681fb0fa64caf82aaa"},
+		{"slip10 1", P256, testSeed1, []uint32{0 + h, 1, 2 + h, 2, 1000000000},
+			"b9b7b82d326bb9cb5b5b121066feea4eb93d5241103c9e7a18aad40f1dde8059", "21c4f269ef0a5fd1badf47eeacebeeaa3de22eb8e5b0adcd0f27dd99d34d0119"},
+		// SLIP-10 test vector 2 for nist256p1
+		{"slip10 2", P256, testSeed2, nil,
+			"96cd4465a9644e31528eda3592aa35eb39a9527769ce1855beafc1b81055e75d", "eaa31c2e46ca2962227cf21d73a7ef0ce8b31c756897521eb6c7b39796633357"},
+		// SLIP-10 derivation retry for nist256p1: the first candidate for m/28578H/33941 is past the curve's order
+		{"slip10 derivation retry", P256, testSeed1, []uint32{28578 + h},
+			"e94c8ebe30c2250a14713212f6449b20f3329105ea15b652ca5bdfc68f6c65c2", "06f0db126f023755d0b8d86d4591718a5210dd8d024e3e14b6159d63f53aa669"},
+		{"slip10 derivation retry", P256, testSeed1, []uint32{28578 + h, 33941},
+			"9e87fe95031f14736774cd82f25fd885065cb7c358c1edf813c72af535e83071", "092154eed4af83e078ff9b84322015aefe5769e31270f62c3f66c33888335f3a"},
+		// SLIP-10 seed retry for nist256p1: the first master key candidate is past the curve's order
+		{"slip10 seed retry", P256, "a7305bc8df8d0951f0cb224c0e95d7707cbdf2c6ce7e8d481fec69c7ff5e9446", nil,
+			"7762f9729fed06121fd13f326884c82f59aa95c57ac492ce8c9654e60efd130c", "3b8c18469a4634517d6d0b65448f8e6c62091b45540a1743c5846be55d47d88f"},
+	} {
+		seed, err := hex.DecodeString(test.seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := fmt.Sprintf("%s m%s", test.name, formatPath(test.path))
+		key := NewMasterKey(seed, test.curve).DerivePath(test.path)
+		if chainCode := hex.EncodeToString(key.ChainCode); chainCode != test.chainCode {
+			t.Errorf("%s: got chain code %s, want %s", name, chainCode, test.chainCode)
+		}
+		if private := hex.EncodeToString(key.Key); private != test.key {
+			t.Errorf("%s: got key %s, want %s", name, private, test.key)
+		}
+	}
+}
+
+// formatPath writes a derivation path the way the test vectors do, e.g. /0H/1
+func formatPath(path []uint32) string {
+	var s string
+	for _, index := range path {
+		if index >= HardenedOffset {
+			s += fmt.Sprintf("/%dH", index-HardenedOffset)
+		} else {
+			s += fmt.Sprintf("/%d", index)
+		}
+	}
+	return s
+}
+
+func TestRestoreSeed(t *testing.T) {
+	for _, test := range []struct {
+		mnemonic, seed string
+	}{
+		// BIP-39 test vector mnemonics, with the seeds they give without a passphrase
+		{"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+			"5eb00bbddcf069084889a8ab9155568165f5c453ccb85e70811aaed6f6da5fc19a5ac40b389cd370d086206dec8aa6c43daea6690f20ad3d8d48b2d2ce9e38e4"},
+		// Extra whitespace between words doesn't change the seed
+		{"  legal winner thank year wave sausage\tworth useful legal winner thank yellow \n",
+			"878386efb78845b3355bd15ea4d39ef97d179cb712b77d5c12b6be415fffeffe5f377ba02bf3f8544ab800b955e51fbff09828f682052a20faa6addbbddfb096"},
+	} {
+		ws := &Wallets{Wallets: make(map[string]*Wallet), Scripts: make(map[string][]byte), network: &config.Regtest}
+		if _, err := ws.Restore(test.mnemonic, func(string) bool { return false }); err != nil {
+			t.Errorf("%q: %v", test.mnemonic, err)
+			continue
+		}
+		if seed := hex.EncodeToString(ws.Seed); seed != test.seed {
+			t.Errorf("%q: got seed %s, want %s", test.mnemonic, seed, test.seed)
+		}
+		// With nothing used, the first P-256 address is restored
+		want := NewMasterKey(ws.Seed, P256).DerivePath(AddressPath(0)).Wallet()
+		if _, err := ws.GetWallet(string(want.GetChromaAddress(&config.Regtest))); err != nil || len(ws.Wallets) != 1 {
+			t.Errorf("%q: restored %d wallets without the first P-256 address (%v)", test.mnemonic, len(ws.Wallets), err)
+		}
+	}
+
+	ws := &Wallets{Wallets: make(map[string]*Wallet), Scripts: make(map[string][]byte), network: &config.Regtest}
+	badChecksum := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon"
+	if _, err := ws.Restore(badChecksum, func(string) bool { return false }); err == nil {
+		t.Error("mnemonic with a bad checksum was restored")
+	}
+}
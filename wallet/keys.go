@@ -0,0 +1,167 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec"
+	conf "github.com/casalettoj/chroma/constants"
+)
+
+// KeyCurve is the elliptic curve a key pair is on. It's recorded in front of every public key,
+// so keys on different curves can lock outputs side by side.
+type KeyCurve byte
+
+const (
+	// P256 is NIST P-256, the curve CHROMA keys were first made on
+	P256 KeyCurve = 0x01
+	// Secp256k1 is the curve bitcoin keys are on
+	Secp256k1 KeyCurve = 0x02
+)
+
+// Curve returns the elliptic curve, or nil if c isn't a known curve
+func (c KeyCurve) Curve() elliptic.Curve {
+	switch c {
+	case P256:
+		return elliptic.P256()
+	case Secp256k1:
+		return btcec.S256()
+	}
+	return nil
+}
+
+// String returns the name of the curve, as ParseKeyCurve takes it
+func (c KeyCurve) String() string {
+	switch c {
+	case P256:
+		return "p256"
+	case Secp256k1:
+		return "secp256k1"
+	}
+	return fmt.Sprintf("unknown curve %d", byte(c))
+}
+
+// ParseKeyCurve returns the curve with a name String returns
+func ParseKeyCurve(name string) (KeyCurve, error) {
+	for _, c := range []KeyCurve{P256, Secp256k1} {
+		if c.String() == name {
+			return c, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown curve %q, must be %s or %s", name, P256, Secp256k1)
+}
+
+// seedKey returns the HMAC key a seed is hashed with to get the master key of a key tree on the curve
+func (c KeyCurve) seedKey() string {
+	if c == Secp256k1 {
+		return conf.HDseedkeysecp256k1
+	}
+	return conf.HDseedkey
+}
+
+// MarshalPublicKey encodes a public key as its curve followed by the compressed SEC1 encoding of its point
+func MarshalPublicKey(c KeyCurve, x, y *big.Int) []byte {
+	return append([]byte{byte(c)}, compressPublicKey(x, y)...)
+}
+
+// ParsePublicKey decodes a public key MarshalPublicKey encoded, returning the curve it is on.
+// The point may also be uncompressed, and a bare uncompressed point (0x04||X||Y), as keys were encoded before
+// curves were recorded, is taken to be on P-256 so outputs locked to those keys stay spendable.
+func ParsePublicKey(data []byte) (*ecdsa.PublicKey, KeyCurve, error) {
+	if len(data) > 0 && data[0] == conf.UncompressedPubKeyPrefix {
+		x, y, err := parseLegacyPoint(data[1:])
+		if err != nil {
+			return nil, 0, err
+		}
+		return &ecdsa.PublicKey{Curve: P256.Curve(), X: x, Y: y}, P256, nil
+	}
+	if len(data) == 0 {
+		return nil, 0, errors.New("empty public key")
+	}
+	c := KeyCurve(data[0])
+	if c.Curve() == nil {
+		return nil, 0, fmt.Errorf("public key is on an %s", c)
+	}
+	x, y, err := parsePoint(c, data[1:])
+	if err != nil {
+		return nil, 0, err
+	}
+	return &ecdsa.PublicKey{Curve: c.Curve(), X: x, Y: y}, c, nil
+}
+
+// parseLegacyPoint decodes the X||Y of a key encoded before curves were recorded. The first wallets didn't pad the
+// coordinates to 32 bytes, so when one had leading zero bytes the key is shorter and the split between them has
+// to be found: it's the one that puts the point on P-256.
+func parseLegacyPoint(data []byte) (x, y *big.Int, err error) {
+	params := P256.Curve().Params()
+	for xLen := 32; xLen >= len(data)-32 && xLen > 0; xLen-- {
+		if xLen >= len(data) {
+			continue
+		}
+		x = new(big.Int).SetBytes(data[:xLen])
+		y = new(big.Int).SetBytes(data[xLen:])
+		if x.Cmp(params.P) < 0 && y.Cmp(params.P) < 0 && params.IsOnCurve(x, y) {
+			return x, y, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("public key isn't a point on %s", P256)
+}
+
+// parsePoint decodes a compressed or uncompressed SEC1 point on the curve
+func parsePoint(c KeyCurve, data []byte) (x, y *big.Int, err error) {
+	params := c.Curve().Params()
+	switch {
+	case len(data) == 65 && data[0] == conf.UncompressedPubKeyPrefix:
+		x = new(big.Int).SetBytes(data[1:33])
+		y = new(big.Int).SetBytes(data[33:])
+	case len(data) == 33 && (data[0] == 0x02 || data[0] == 0x03):
+		x = new(big.Int).SetBytes(data[1:])
+		// y² = x³ + ax + b, where a is -3 on P-256 and 0 on secp256k1
+		y2 := new(big.Int).Exp(x, big.NewInt(3), params.P)
+		if c == P256 {
+			threeX := new(big.Int).Lsh(x, 1)
+			threeX.Add(threeX, x)
+			y2.Sub(y2, threeX)
+		}
+		y2.Add(y2, params.B)
+		y2.Mod(y2, params.P)
+		y = new(big.Int).ModSqrt(y2, params.P)
+		if y == nil {
+			return nil, nil, errors.New("public key's X coordinate isn't on the curve")
+		}
+		if y.Bit(0) != uint(data[0]&1) {
+			y.Sub(params.P, y)
+		}
+	default:
+		return nil, nil, fmt.Errorf("public key is %d bytes, not a compressed or uncompressed point", len(data))
+	}
+	if x.Cmp(params.P) >= 0 || y.Cmp(params.P) >= 0 || !c.Curve().IsOnCurve(x, y) {
+		return nil, nil, fmt.Errorf("public key isn't a point on %s", c)
+	}
+	return x, y, nil
+}
+
+// newPrivateKey returns the key pair with private key d on the curve
+func newPrivateKey(c KeyCurve, d *big.Int) ecdsa.PrivateKey {
+	private := ecdsa.PrivateKey{D: d}
+	private.PublicKey.Curve = c.Curve()
+	private.PublicKey.X, private.PublicKey.Y = c.Curve().ScalarBaseMult(padTo32(d.Bytes()))
+	return private
+}
+
+// compressPublicKey serializes a point as its X coordinate, prefixed with whether Y is even (0x02) or odd (0x03)
+func compressPublicKey(x, y *big.Int) []byte {
+	prefix := byte(0x02)
+	if y.Bit(0) == 1 {
+		prefix = 0x03
+	}
+	return append([]byte{prefix}, padTo32(x.Bytes())...)
+}
+
+// uncompressedPublicKey serializes a point as 0x04||X||Y, how P-256 keys were encoded before curves were recorded
+func uncompressedPublicKey(x, y *big.Int) []byte {
+	return append(append([]byte{conf.UncompressedPubKeyPrefix}, padTo32(x.Bytes())...), padTo32(y.Bytes())...)
+}
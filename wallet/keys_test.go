@@ -0,0 +1,56 @@
+package wallet
+
+import (
+	"math/big"
+	"testing"
+
+	conf "github.com/casalettoj/chroma/constants"
+)
+
+// legacyKey encodes a point the way the first wallets did: 0x04||X||Y with the coordinates unpadded
+func legacyKey(x, y *big.Int) []byte {
+	return append(append([]byte{conf.UncompressedPubKeyPrefix}, x.Bytes()...), y.Bytes()...)
+}
+
+func TestParsePublicKey(t *testing.T) {
+	// Find keys whose X and Y have a leading zero byte, so their legacy encodings are short
+	var shortX, shortY *Wallet
+	for d := int64(1); shortX == nil || shortY == nil; d++ {
+		w := &Wallet{Curve: P256, PrivateKey: newPrivateKey(P256, big.NewInt(d))}
+		if shortX == nil && len(w.PrivateKey.X.Bytes()) < 32 {
+			shortX = w
+		}
+		if shortY == nil && len(w.PrivateKey.Y.Bytes()) < 32 {
+			shortY = w
+		}
+	}
+
+	for _, test := range []struct {
+		name  string
+		curve KeyCurve
+		w     *Wallet
+		data  []byte
+	}{
+		{"compressed p256", P256, shortX, MarshalPublicKey(P256, shortX.PrivateKey.X, shortX.PrivateKey.Y)},
+		{"padded legacy", P256, shortX, uncompressedPublicKey(shortX.PrivateKey.X, shortX.PrivateKey.Y)},
+		{"legacy with a short X", P256, shortX, legacyKey(shortX.PrivateKey.X, shortX.PrivateKey.Y)},
+		{"legacy with a short Y", P256, shortY, legacyKey(shortY.PrivateKey.X, shortY.PrivateKey.Y)},
+	} {
+		key, curve, err := ParsePublicKey(test.data)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if curve != test.curve || key.X.Cmp(test.w.PrivateKey.X) != 0 || key.Y.Cmp(test.w.PrivateKey.Y) != 0 {
+			t.Errorf("%s: got a different key", test.name)
+		}
+	}
+
+	bad := legacyKey(shortX.PrivateKey.X, shortX.PrivateKey.Y)
+	bad[len(bad)-1] ^= 1
+	for _, data := range [][]byte{nil, {conf.UncompressedPubKeyPrefix}, bad} {
+		if _, _, err := ParsePublicKey(data); err == nil {
+			t.Errorf("%x was accepted", data)
+		}
+	}
+}
@@ -1,40 +1,54 @@
 package wallet
 
 import (
+	"bytes"
 	"crypto/ecdsa"
-	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/sha256"
+	"errors"
+	"fmt"
 
 	"github.com/btcsuite/btcutil/base58"
+	"github.com/casalettoj/chroma/config"
 	conf "github.com/casalettoj/chroma/constants"
 	util "github.com/casalettoj/chroma/utils"
 	"golang.org/x/crypto/ripemd160"
 )
 
-// Wallet holds a private key
+// ErrInvalidAddress is returned for an address that isn't a valid address of the network
+var ErrInvalidAddress = errors.New("invalid address")
+
+// Wallet holds a private key and the curve it is on
 type Wallet struct {
+	Curve      KeyCurve
 	PrivateKey ecdsa.PrivateKey
 	PublicKey  []byte
 }
 
-// GetChromaAddress returns a public CHROMA address for a wallet
-func (wa *Wallet) GetChromaAddress() []byte {
-	hashedKey := HashPublicKey(wa.PublicKey)
-	payload := append([]byte{conf.Version}, hashedKey...)
+// GetChromaAddress returns the public CHROMA address of a wallet on the network
+func (wa *Wallet) GetChromaAddress(network *config.Network) []byte {
+	return encodeAddress(network.AddressVersion, HashPublicKey(wa.PublicKey))
+}
+
+// GetScriptAddress returns the CHROMA address on the network paying to a script, such as a multisig redeem script
+func GetScriptAddress(network *config.Network, script []byte) []byte {
+	return encodeAddress(network.ScriptHashVersion, HashPublicKey(script))
+}
+
+// encodeAddress base58 encodes a version byte, hash and checksum as an address
+func encodeAddress(version byte, hash []byte) []byte {
+	payload := append([]byte{version}, hash...)
 	checksum := checksum(payload)
 	address := base58.Encode(append(payload, checksum...))
 	return []byte(address)
 }
 
-// NewWallet creates a new wallet
-func NewWallet() *Wallet {
-	curve := elliptic.P256()
-	private, err := ecdsa.GenerateKey(curve, rand.Reader)
+// NewWallet creates a new wallet with a random key on the curve
+func NewWallet(curve KeyCurve) *Wallet {
+	private, err := ecdsa.GenerateKey(curve.Curve(), rand.Reader)
 	util.CheckAnxiety(err)
-	public := append(private.PublicKey.X.Bytes(), private.PublicKey.Y.Bytes()...)
-	public = append([]byte{conf.UncompressedPubKeyPrefix}, public...) // Append prefix for an uncompressed public key -- may do key compression later
-	return &Wallet{PrivateKey: *private, PublicKey: public}
+	public := MarshalPublicKey(curve, private.PublicKey.X, private.PublicKey.Y)
+	return &Wallet{Curve: curve, PrivateKey: *private, PublicKey: public}
 }
 
 // HashPublicKey takes a public key and hashes its SHA256 hash w/ RIPEMD160 to return a public key hash
@@ -46,6 +60,38 @@ func HashPublicKey(pk []byte) []byte {
 	return RIPEMD160hasher.Sum(nil)
 }
 
+// DecodeAddress returns the hash an address of the network pays to and whether it is the hash of a script rather than
+// a public key. Returns an error wrapping ErrInvalidAddress if the address doesn't decode to a version byte
+// of the network, a hash and a matching checksum.
+func DecodeAddress(network *config.Network, address string) (hash []byte, isScript bool, err error) {
+	payload := base58.Decode(address)
+	if len(payload) != 1+ripemd160.Size+conf.AddressChecksumLen {
+		return nil, false, fmt.Errorf("%w: %s", ErrInvalidAddress, address)
+	}
+	versionedHash := payload[:len(payload)-conf.AddressChecksumLen]
+	actualChecksum := payload[len(payload)-conf.AddressChecksumLen:]
+	if bytes.Compare(actualChecksum, checksum(versionedHash)) != 0 {
+		return nil, false, fmt.Errorf("%w: %s has a bad checksum", ErrInvalidAddress, address)
+	}
+	if versionedHash[0] != network.AddressVersion && versionedHash[0] != network.ScriptHashVersion {
+		return nil, false, fmt.Errorf("%w: %s isn't a %s address", ErrInvalidAddress, address, network.Name)
+	}
+	return versionedHash[1:], versionedHash[0] == network.ScriptHashVersion, nil
+}
+
+// ValidateAddress returns whether an address decodes to a version byte of the network and a matching checksum
+func ValidateAddress(network *config.Network, address string) bool {
+	_, _, err := DecodeAddress(network, address)
+	return err == nil
+}
+
+// IsScriptAddress returns whether an address of the network is valid and pays to a script hash rather than
+// a public key hash
+func IsScriptAddress(network *config.Network, address string) bool {
+	_, isScript, err := DecodeAddress(network, address)
+	return err == nil && isScript
+}
+
 // checksum hashes a byte array twice with sha256 and returns a bytearray of AddressChecksumLen length
 func checksum(pl []byte) []byte {
 	hashedKey := sha256.Sum256(pl)
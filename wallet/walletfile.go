@@ -0,0 +1,173 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+
+	"github.com/casalettoj/chroma/config"
+	conf "github.com/casalettoj/chroma/constants"
+	util "github.com/casalettoj/chroma/utils"
+	"golang.org/x/crypto/scrypt"
+)
+
+// ErrWrongPassphrase is returned when the wallet file can't be decrypted with the passphrase given
+var ErrWrongPassphrase = errors.New("wrong passphrase")
+
+// kdfParams are the scrypt parameters and salt a wallet file's key is derived from its passphrase with
+type kdfParams struct {
+	N, R, P int
+	Salt    []byte
+}
+
+// encryptedFile is what follows the magic bytes of the wallet file: the wallets, encrypted with AES-256-GCM
+type encryptedFile struct {
+	KDF        kdfParams
+	Nonce      []byte
+	Ciphertext []byte
+}
+
+// storedWallets is what gets encrypted: the wallets with their keys reduced to what gob can encode
+type storedWallets struct {
+	Wallets   map[string]*storedWallet
+	Scripts   map[string][]byte
+	Seed      []byte
+	NextIndex uint32
+}
+
+// storedWallet is a Wallet without the curve of its private key, which can't be gob encoded.
+// Field names match Wallet's so plaintext files from before encryption, which held whole Wallets, decode into it.
+// Curve is zero in files from before keys were recorded with their curve, when they were all on P-256.
+type storedWallet struct {
+	Curve      KeyCurve
+	PrivateKey storedPrivateKey
+	PublicKey  []byte
+}
+
+// storedPrivateKey is the part of an ecdsa.PrivateKey the rest can be rebuilt from
+type storedPrivateKey struct {
+	D *big.Int
+}
+
+// IsWalletFileEncrypted returns whether the network's wallet file exists and is encrypted
+func IsWalletFileEncrypted(cfg *config.Config) bool {
+	content, err := ioutil.ReadFile(cfg.WalletFile())
+	return err == nil && bytes.HasPrefix(content, []byte(conf.WalletMagic))
+}
+
+// SetPassphrase sets the passphrase the wallets are encrypted with when saved, under a new salt
+func (ws *Wallets) SetPassphrase(passphrase string) {
+	salt := make([]byte, conf.WalletSaltLen)
+	_, err := rand.Read(salt)
+	util.CheckAnxiety(err)
+	ws.kdf = kdfParams{N: conf.WalletScryptN, R: conf.WalletScryptR, P: conf.WalletScryptP, Salt: salt}
+	key, err := deriveKey(passphrase, ws.kdf)
+	util.CheckAnxiety(err)
+	ws.key = key
+}
+
+// encrypt returns the wallet file contents for the wallets
+func (ws *Wallets) encrypt() []byte {
+	var plaintext bytes.Buffer
+	util.CheckAnxiety(gob.NewEncoder(&plaintext).Encode(ws.store()))
+
+	aead, err := newAEAD(ws.key)
+	util.CheckAnxiety(err)
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	util.CheckAnxiety(err)
+	file := encryptedFile{KDF: ws.kdf, Nonce: nonce, Ciphertext: aead.Seal(nil, nonce, plaintext.Bytes(), []byte(conf.WalletMagic))}
+
+	content := bytes.NewBufferString(conf.WalletMagic)
+	util.CheckAnxiety(gob.NewEncoder(content).Encode(file))
+	return content.Bytes()
+}
+
+// decryptWallets returns the wallets in the contents of an encrypted wallet file
+func decryptWallets(content []byte, passphrase string) (*Wallets, error) {
+	var file encryptedFile
+	if err := gob.NewDecoder(bytes.NewReader(content[len(conf.WalletMagic):])).Decode(&file); err != nil {
+		return nil, err
+	}
+	key, err := deriveKey(passphrase, file.KDF)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(file.Nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("wallet file has a %d byte nonce, not %d", len(file.Nonce), aead.NonceSize())
+	}
+	plaintext, err := aead.Open(nil, file.Nonce, file.Ciphertext, []byte(conf.WalletMagic))
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	wallets, err := decodeWallets(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	wallets.kdf = file.KDF
+	wallets.key = key
+	return wallets, nil
+}
+
+// decodeWallets decodes gob encoded storedWallets, as encrypted files hold and plaintext files are
+func decodeWallets(data []byte) (*Wallets, error) {
+	var stored storedWallets
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&stored); err != nil {
+		return nil, err
+	}
+	wallets := &Wallets{Wallets: make(map[string]*Wallet), Scripts: stored.Scripts, Seed: stored.Seed, NextIndex: stored.NextIndex}
+	// Wallet files from before multisig support have no scripts
+	if wallets.Scripts == nil {
+		wallets.Scripts = make(map[string][]byte)
+	}
+	for address, w := range stored.Wallets {
+		curve := w.Curve
+		if curve == 0 {
+			curve = P256
+		}
+		if curve.Curve() == nil {
+			return nil, fmt.Errorf("wallet %s has a key on an %s", address, curve)
+		}
+		wallets.Wallets[address] = &Wallet{Curve: curve, PrivateKey: newPrivateKey(curve, w.PrivateKey.D), PublicKey: w.PublicKey}
+	}
+	return wallets, nil
+}
+
+// store returns the wallets as they are encoded in the wallet file
+func (ws *Wallets) store() storedWallets {
+	stored := storedWallets{Wallets: make(map[string]*storedWallet), Scripts: ws.Scripts, Seed: ws.Seed, NextIndex: ws.NextIndex}
+	for address, w := range ws.Wallets {
+		stored.Wallets[address] = &storedWallet{Curve: w.Curve, PrivateKey: storedPrivateKey{D: w.PrivateKey.D}, PublicKey: w.PublicKey}
+	}
+	return stored
+}
+
+// deriveKey derives the AES-256 key of a wallet file from its passphrase.
+// Returns an error if the scrypt parameters, read from the file, are past the limits or invalid.
+func deriveKey(passphrase string, kdf kdfParams) ([]byte, error) {
+	if kdf.N < 2 || kdf.N > conf.WalletScryptmaxN || kdf.R < 1 || kdf.R > conf.WalletScryptmaxR ||
+		kdf.P < 1 || kdf.P > conf.WalletScryptmaxP {
+		return nil, fmt.Errorf("wallet file asks for scrypt N=%d, r=%d, p=%d, past the limits of N=%d, r=%d, p=%d",
+			kdf.N, kdf.R, kdf.P, conf.WalletScryptmaxN, conf.WalletScryptmaxR, conf.WalletScryptmaxP)
+	}
+	return scrypt.Key([]byte(passphrase), kdf.Salt, kdf.N, kdf.R, kdf.P, 32)
+}
+
+// newAEAD returns AES-256-GCM under key
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
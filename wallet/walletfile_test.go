@@ -0,0 +1,169 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/casalettoj/chroma/config"
+	conf "github.com/casalettoj/chroma/constants"
+)
+
+// sameWallets fails the test unless got holds the same keys, scripts and seed as want
+func sameWallets(t *testing.T, got, want *Wallets) {
+	t.Helper()
+	if len(got.Wallets) != len(want.Wallets) {
+		t.Fatalf("got %d wallets, want %d", len(got.Wallets), len(want.Wallets))
+	}
+	for address, w := range want.Wallets {
+		g, err := got.GetWallet(address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if g.Curve != w.Curve || g.PrivateKey.D.Cmp(w.PrivateKey.D) != 0 || !bytes.Equal(g.PublicKey, w.PublicKey) {
+			t.Errorf("wallet %s came back different", address)
+		}
+	}
+	for address, script := range want.Scripts {
+		if !bytes.Equal(got.GetScript(address), script) {
+			t.Errorf("script of %s came back different", address)
+		}
+	}
+	if !bytes.Equal(got.Seed, want.Seed) || got.NextIndex != want.NextIndex {
+		t.Errorf("got seed %x at index %d, want %x at %d", got.Seed, got.NextIndex, want.Seed, want.NextIndex)
+	}
+}
+
+func TestWalletFileRoundTrip(t *testing.T) {
+	cfg := &config.Config{DataDir: t.TempDir(), Network: &config.Regtest}
+	wallets, err := OpenWallets(cfg, "old passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wallets.NewSeed()
+	for _, curve := range []KeyCurve{P256, Secp256k1} {
+		if _, err = wallets.AddNewWallet(curve); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wallets.AddScript("script address", []byte("redeem script"))
+	if err = wallets.SaveWallets(); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(cfg.WalletFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(content, []byte(conf.WalletMagic)) || !IsWalletFileEncrypted(cfg) {
+		t.Error("wallet file isn't encrypted")
+	}
+	if bytes.Contains(content, wallets.Seed) || bytes.Contains(content, []byte("redeem script")) {
+		t.Error("wallet file holds its contents in plaintext")
+	}
+	if info, err := os.Stat(cfg.WalletFile()); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("wallet file can be read by others (%v)", err)
+	}
+
+	reopened, err := OpenWallets(cfg, "old passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameWallets(t, reopened, wallets)
+	if _, err = OpenWallets(cfg, "wrong passphrase"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("opening with the wrong passphrase gave %v, want ErrWrongPassphrase", err)
+	}
+
+	// Changing the passphrase, as changepassphrase does, locks out the old one
+	reopened.SetPassphrase("new passphrase")
+	if err = reopened.SaveWallets(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = OpenWallets(cfg, "old passphrase"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("opening with the old passphrase gave %v, want ErrWrongPassphrase", err)
+	}
+	changed, err := OpenWallets(cfg, "new passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameWallets(t, changed, wallets)
+
+	// A file whose ciphertext was tampered with fails to open as if the passphrase were wrong
+	content, err = ioutil.ReadFile(cfg.WalletFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file encryptedFile
+	if err = gob.NewDecoder(bytes.NewReader(content[len(conf.WalletMagic):])).Decode(&file); err != nil {
+		t.Fatal(err)
+	}
+	file.Ciphertext[0] ^= 1
+	tampered := bytes.NewBufferString(conf.WalletMagic)
+	if err = gob.NewEncoder(tampered).Encode(file); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(cfg.WalletFile(), tampered.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = OpenWallets(cfg, "new passphrase"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("opening a tampered file gave %v, want ErrWrongPassphrase", err)
+	}
+}
+
+func TestWalletFileMigration(t *testing.T) {
+	// Wallet files from before encryption were gob encoded Wallets, whose keys had no curve and were all on P-256
+	type legacyPrivateKey struct {
+		D *big.Int
+	}
+	type legacyWallet struct {
+		PrivateKey legacyPrivateKey
+		PublicKey  []byte
+	}
+	type legacyWallets struct {
+		Wallets map[string]*legacyWallet
+	}
+	w := &Wallet{Curve: P256, PrivateKey: newPrivateKey(P256, big.NewInt(0x1234))}
+	w.PublicKey = uncompressedPublicKey(w.PrivateKey.X, w.PrivateKey.Y)
+	address := string(w.GetChromaAddress(&config.Regtest))
+	var legacy bytes.Buffer
+	err := gob.NewEncoder(&legacy).Encode(legacyWallets{Wallets: map[string]*legacyWallet{
+		address: {PrivateKey: legacyPrivateKey{D: w.PrivateKey.D}, PublicKey: w.PublicKey},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{DataDir: t.TempDir(), Network: &config.Regtest}
+	if err = os.MkdirAll(filepath.Dir(cfg.WalletFile()), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(cfg.WalletFile(), legacy.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if IsWalletFileEncrypted(cfg) {
+		t.Fatal("plaintext wallet file counts as encrypted")
+	}
+
+	// Opening it loads the keys and saves them encrypted with the passphrase given
+	migrated, err := OpenWallets(cfg, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Wallets{Wallets: map[string]*Wallet{address: w}}
+	sameWallets(t, migrated, want)
+	if !IsWalletFileEncrypted(cfg) {
+		t.Fatal("migrated wallet file isn't encrypted")
+	}
+	reopened, err := OpenWallets(cfg, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameWallets(t, reopened, want)
+	if _, err = OpenWallets(cfg, "wrong passphrase"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("opening the migrated file with the wrong passphrase gave %v, want ErrWrongPassphrase", err)
+	}
+}
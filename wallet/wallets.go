@@ -2,31 +2,144 @@ package wallet
 
 import (
 	"bytes"
-	"crypto/elliptic"
-	"encoding/gob"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 
+	"github.com/casalettoj/chroma/config"
 	conf "github.com/casalettoj/chroma/constants"
 	util "github.com/casalettoj/chroma/utils"
+	bip39 "github.com/tyler-smith/go-bip39"
+)
+
+var (
+	// ErrWalletNotFound is returned when an address isn't one of the wallets'
+	ErrWalletNotFound = errors.New("address isn't in the wallet")
+	// ErrNoSeed is returned when deriving a key for wallets that have no seed
+	ErrNoSeed = errors.New("wallets have no seed to derive keys from")
 )
 
 // Wallets holds private keys mapped by
 type Wallets struct {
 	Wallets map[string]*Wallet
+	// Scripts holds the redeem scripts of multisig addresses, mapped by address
+	Scripts map[string][]byte
+	// Seed is the BIP-39 seed keys are derived from, and NextIndex the index of the next address to derive
+	Seed      []byte
+	NextIndex uint32
+
+	// kdf and key are what the wallets are encrypted with when saved, set from a passphrase
+	kdf kdfParams
+	key []byte
+	// file is where the wallets are saved
+	file string
+	// network is the network the wallets' addresses are on
+	network *config.Network
 }
 
-// AddNewWallet creates a new private/public key pair and adds it to the wallet.
-func (ws *Wallets) AddNewWallet() (address string) {
-	wallet := NewWallet()
-	address = string(wallet.GetChromaAddress())
-	ws.Wallets[address] = wallet
+// HasSeed returns whether the wallets have a seed to derive keys from
+func (ws *Wallets) HasSeed() bool {
+	return ws.Seed != nil
+}
+
+// NewSeed gives the wallets a new seed to derive keys from, returning the BIP-39 mnemonic it comes from for backup
+func (ws *Wallets) NewSeed() (mnemonic string) {
+	entropy, err := bip39.NewEntropy(conf.HDmnemonicbits)
+	util.CheckAnxiety(err)
+	mnemonic, err = bip39.NewMnemonic(entropy)
+	util.CheckAnxiety(err)
+	ws.Seed = bip39.NewSeed(mnemonic, "")
+	ws.NextIndex = 0
 	return
 }
 
-// GetWallet returns the wallet stored at the address specified
-func (ws Wallets) GetWallet(address string) Wallet {
-	return *ws.Wallets[address]
+// AddNewWallet derives the key pair of the next address on the curve from the seed and adds it to the wallet.
+// Each curve has its own key tree, but addresses share one index so every index holds a single address.
+// Returns ErrNoSeed if the wallets have no seed.
+func (ws *Wallets) AddNewWallet(curve KeyCurve) (address string, err error) {
+	if !ws.HasSeed() {
+		return "", ErrNoSeed
+	}
+	wallet := NewMasterKey(ws.Seed, curve).DerivePath(AddressPath(ws.NextIndex)).Wallet()
+	ws.NextIndex++
+	address = string(wallet.GetChromaAddress(ws.network))
+	ws.Wallets[address] = wallet
+	return address, nil
+}
+
+// Restore sets the seed from a BIP-39 mnemonic and adds the key pairs derived from it, scanning indexes in order
+// until HDgaplimit in a row haven't been used. At each index up to the last used one, the addresses of every curve
+// that were used are kept, or the P-256 one if none were (just the first P-256 address if nothing was used at all).
+// P-256 addresses of keys encoded the way they were before curves were recorded are looked for too.
+// Returns the number of indexes restored.
+func (ws *Wallets) Restore(mnemonic string, used func(address string) bool) (int, error) {
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, "")
+	if err != nil {
+		return 0, err
+	}
+
+	masters := []*ExtendedKey{NewMasterKey(seed, P256), NewMasterKey(seed, Secp256k1)}
+	var derived [][]*Wallet
+	lastUsed := -1
+	for index := 0; index-lastUsed <= conf.HDgaplimit; index++ {
+		var candidates []*Wallet
+		for _, master := range masters {
+			wallet := master.DerivePath(AddressPath(uint32(index))).Wallet()
+			candidates = append(candidates, wallet)
+			if wallet.Curve == P256 {
+				legacy := *wallet
+				legacy.PublicKey = uncompressedPublicKey(wallet.PrivateKey.X, wallet.PrivateKey.Y)
+				candidates = append(candidates, &legacy)
+			}
+		}
+		var found []*Wallet
+		for _, wallet := range candidates {
+			if used(string(wallet.GetChromaAddress(ws.network))) {
+				found = append(found, wallet)
+			}
+		}
+		if found == nil {
+			found = candidates[:1]
+		} else {
+			lastUsed = index
+		}
+		derived = append(derived, found)
+	}
+
+	restored := lastUsed + 1
+	if restored == 0 {
+		restored = 1
+	}
+	for _, found := range derived[:restored] {
+		for _, wallet := range found {
+			ws.Wallets[string(wallet.GetChromaAddress(ws.network))] = wallet
+		}
+	}
+	ws.Seed = seed
+	ws.NextIndex = uint32(restored)
+	return restored, nil
+}
+
+// GetWallet returns the wallet stored at the address specified, or an error wrapping ErrWalletNotFound
+func (ws Wallets) GetWallet(address string) (Wallet, error) {
+	wallet, ok := ws.Wallets[address]
+	if !ok {
+		return Wallet{}, fmt.Errorf("%w: %s", ErrWalletNotFound, address)
+	}
+	return *wallet, nil
+}
+
+// AddScript stores the redeem script of a script address
+func (ws *Wallets) AddScript(address string, script []byte) {
+	ws.Scripts[address] = script
+}
+
+// GetScript returns the redeem script stored for a script address, or nil if there isn't one
+func (ws Wallets) GetScript(address string) []byte {
+	return ws.Scripts[address]
 }
 
 // GetAddresses returns a string array of address keys in the Wallets collection
@@ -37,29 +150,58 @@ func (ws *Wallets) GetAddresses() (addresses []string) {
 	return
 }
 
-// SaveWallets saves the wallets data to a file
-func (ws Wallets) SaveWallets() {
-	var content bytes.Buffer
-	gob.Register(elliptic.P256())
-	encoder := gob.NewEncoder(&content)
-	util.CheckAnxiety(encoder.Encode(ws))
-	util.CheckAnxiety(ioutil.WriteFile(conf.WalletFile, content.Bytes(), 0644))
+// SaveWallets encrypts the wallets data with their passphrase and saves it to a file only the user can read
+func (ws Wallets) SaveWallets() error {
+	if ws.key == nil {
+		return errors.New("wallets have no passphrase to be encrypted with")
+	}
+	// Write a temporary file first so a failed write can't leave a corrupt wallet file behind
+	tempFile := ws.file + ".tmp"
+	if err := ioutil.WriteFile(tempFile, ws.encrypt(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tempFile, ws.file)
 }
 
-// OpenWallets creates a new wallets file if none exists otherwise loads from file and returns result
-func OpenWallets() *Wallets {
-	_, err := os.Stat(conf.WalletFile)
+// OpenWallets creates a new wallets file for cfg's network encrypted with passphrase if none exists,
+// otherwise decrypts it with passphrase.
+// Returns ErrWrongPassphrase if it doesn't decrypt the file.
+// A plaintext file from before encryption is loaded and saved encrypted with passphrase from then on.
+func OpenWallets(cfg *config.Config, passphrase string) (*Wallets, error) {
+	content, err := ioutil.ReadFile(cfg.WalletFile())
 	if os.IsNotExist(err) {
-		wallets := Wallets{}
-		wallets.Wallets = make(map[string]*Wallet)
-		wallets.SaveWallets()
-		return &wallets
+		wallets := &Wallets{Wallets: make(map[string]*Wallet), Scripts: make(map[string][]byte), file: cfg.WalletFile(), network: cfg.Network}
+		wallets.SetPassphrase(passphrase)
+		if err = cfg.MakeDir(); err != nil {
+			return nil, err
+		}
+		if err = wallets.SaveWallets(); err != nil {
+			return nil, err
+		}
+		return wallets, nil
 	}
-	content, err := ioutil.ReadFile(conf.WalletFile)
-	util.CheckAnxiety(err)
-	var wallets Wallets
-	gob.Register(elliptic.P256())
-	decoder := gob.NewDecoder(bytes.NewReader(content))
-	util.CheckAnxiety(decoder.Decode(&wallets))
-	return &wallets
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(content, []byte(conf.WalletMagic)) {
+		wallets, err := decryptWallets(content, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		wallets.file = cfg.WalletFile()
+		wallets.network = cfg.Network
+		return wallets, nil
+	}
+
+	wallets, err := decodeWallets(content)
+	if err != nil {
+		return nil, err
+	}
+	wallets.file = cfg.WalletFile()
+	wallets.network = cfg.Network
+	wallets.SetPassphrase(passphrase)
+	if err = wallets.SaveWallets(); err != nil {
+		return nil, err
+	}
+	return wallets, nil
 }